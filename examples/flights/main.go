@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/getsynq/cloud/ai-data-sre/examples"
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
@@ -95,6 +98,24 @@ func (b *BookFlightTool) Run(ctx context.Context, args *BookRequest) (*Booking,
 	}, nil
 }
 
+// APPROVAL
+
+// consoleApprover asks on the terminal before a flight is booked
+func consoleApprover(ctx context.Context, toolCall *tools.ToolCall) (*tools.ApprovalDecision, error) {
+	fmt.Printf("Approve %s %s? [y/N] ", toolCall.Name, toolCall.Args)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(strings.TrimSpace(answer), "y") {
+		return tools.Approve(), nil
+	}
+
+	return tools.Reject("user declined the booking"), nil
+}
+
 // AGENT
 
 var travelAgent = workflows.NewAgentTask(
@@ -117,9 +138,10 @@ var travelAgent = workflows.NewAgentTask(
 		ai.WithModel(ai.Gemini25Flash),
 		ai.WithTemperature(0.0),
 		ai.WithMaxCompletionTokens(1000),
-		ai.WithTools(tools.NewAdapter(NewBookFlightTool()), searchFlightsTool),
+		ai.WithTools(tools.RequireApproval(tools.NewAdapter(NewBookFlightTool())), searchFlightsTool),
 	),
 	agent.WithEvents(logger),
+	agent.WithApprover(consoleApprover),
 )
 
 // FINAL FORMATTER
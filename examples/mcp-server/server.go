@@ -2,20 +2,28 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"time"
 
-	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/mcpserver"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
 )
 
 // GetTimeParams defines the parameters for the cityTime tool.
 type GetTimeParams struct {
-	City string `json:"city" jsonschema:"City to get time for (nyc, sf, or boston)"`
+	City string `json:"city" jsonschema_description:"City to get time for (nyc, sf, or boston)"`
+}
+
+type GetTimeResult struct {
+	City string `json:"city"`
+	Time string `json:"time"`
 }
 
 // getTime implements the tool that returns the current time for a given city.
-func getTime(ctx context.Context, req *mcp.CallToolRequest, params *GetTimeParams) (*mcp.CallToolResult, any, error) {
+func getTime(ctx context.Context, params *GetTimeParams) (*GetTimeResult, error) {
 	// Define time zones for each city
 	locations := map[string]string{
 		"nyc":    "America/New_York",
@@ -31,82 +39,59 @@ func getTime(ctx context.Context, req *mcp.CallToolRequest, params *GetTimeParam
 	// Get the timezone.
 	tzName, ok := locations[city]
 	if !ok {
-		return nil, nil, fmt.Errorf("unknown city: %s", city)
+		return nil, fmt.Errorf("unknown city: %s", city)
 	}
 
 	// Load the location.
 	loc, err := time.LoadLocation(tzName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load timezone: %w", err)
+		return nil, fmt.Errorf("failed to load timezone: %w", err)
 	}
 
-	// Get current time in that location.
-	now := time.Now().In(loc)
-
-	// Format the response.
 	cityNames := map[string]string{
 		"nyc":    "New York City",
 		"sf":     "San Francisco",
 		"boston": "Boston",
 	}
 
-	response := fmt.Sprintf("The current time in %s is %s",
-		cityNames[city],
-		now.Format(time.RFC3339))
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: response},
-		},
-	}, nil, nil
+	return &GetTimeResult{City: cityNames[city], Time: time.Now().In(loc).Format(time.RFC3339)}, nil
 }
 
 type GreetParams struct {
-	Name string `json:"name" jsonschema:"Name to greet"`
+	Name string `json:"name" jsonschema_description:"Name to greet"`
 }
 
-func greet(ctx context.Context, req *mcp.CallToolRequest, params *GreetParams) (*mcp.CallToolResult, any, error) {
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: "Hello, " + params.Name},
-		},
-	}, nil, nil
+type GreetResult struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(ctx context.Context, params *GreetParams) (*GreetResult, error) {
+	return &GreetResult{Greeting: "Hello, " + params.Name}, nil
 }
 
 func main() {
-	// Create an MCP server.
-	server := mcp.NewServer(&mcp.Implementation{
-		Name:    "time-server",
-		Version: "1.0.0",
-	}, nil)
-
-	// Add the cityTime tool.
-	mcp.AddTool(server, &mcp.Tool{
-		Name:        "cityTime",
-		Description: "Get the current time in NYC, San Francisco, or Boston",
-	}, getTime)
-
-	mcp.AddTool(server, &mcp.Tool{
-		Name:        "greet",
-		Description: "Greet a person",
-	}, greet)
-
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatal(err)
-	}
+	addr := flag.String("http", "", "serve over streamable HTTP on this address, e.g. localhost:8080, instead of stdio")
+	flag.Parse()
 
-	// // Create the streamable HTTP handler.
-	// handler := mcp.NewStreamableHTTPHandler(func(req *http.Request) *mcp.Server {
-	// 	return server
-	// }, nil)
+	server := mcpserver.NewServer("time-server", "1.0.0")
 
-	// url := "localhost:8080"
+	err := server.AddTools(tools.NewToolbox(
+		tools.NewSimpleTool("cityTime", "Get the current time in NYC, San Francisco, or Boston", getTime),
+		tools.NewSimpleTool("greet", "Greet a person", greet),
+	))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// log.Printf("MCP server listening on %s", url)
-	// log.Printf("Available tool: cityTime (cities: nyc, sf, boston)")
+	if *addr == "" {
+		if err := server.Run(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// // Start the HTTP server with logging handler.
-	// if err := http.ListenAndServe(url, handler); err != nil {
-	// 	log.Fatalf("Server failed: %v", err)
-	// }
+	log.Printf("MCP server listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, server.Handler()); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
 }
@@ -161,7 +161,7 @@ func main() {
 	litellm := examples.GetLiteLLM()
 
 	memory := workflows.NewMemoryStorageProvider()
-	ctx = workflows.WithStorage(ctx, memory.Storage("travel-1"))
+	ctx = workflows.WithStorage(ctx, memory.Storage(ctx, "travel-1"))
 
 	prompt := "I want to book a flight to Tokyo, 1st Oct and back 8th Oct"
 
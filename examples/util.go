@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/adapters/anthropic"
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/adapters/openai"
 )
 
@@ -23,3 +24,19 @@ func GetLiteLLM() ai.LLM {
 	// Create OpenAI adapter
 	return openai.NewOpenAIAdapter(apiKey, openai.WithEndpoint(endpoint))
 }
+
+func GetAnthropic() ai.LLM {
+	// Get Anthropic API key from environment
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		log.Fatal("ANTHROPIC_API_KEY environment variable is required")
+	}
+
+	opts := []anthropic.AnthropicAdapterOpts{}
+	if endpoint := os.Getenv("ANTHROPIC_ENDPOINT"); endpoint != "" {
+		opts = append(opts, anthropic.WithEndpoint(endpoint))
+	}
+
+	// Create Anthropic adapter
+	return anthropic.NewAnthropicAdapter(apiKey, opts...)
+}
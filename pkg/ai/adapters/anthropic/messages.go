@@ -0,0 +1,385 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultEndpoint  = "https://api.anthropic.com/v1"
+	DefaultVersion   = "2023-06-01"
+	DefaultMaxTokens = 4096
+)
+
+// DefaultModels maps our model ids to Anthropic model names. Model ids
+// that are not listed are sent to the API as they are.
+var DefaultModels = map[ai.ModelId]string{
+	ai.Claude3Sonnet: "claude-3-7-sonnet-latest",
+	ai.Claude4Sonnet: "claude-sonnet-4-0",
+	ai.Claude3Haiku:  "claude-3-5-haiku-latest",
+}
+
+// AnthropicAdapter implements the LLM interface using Anthropic's Messages API
+type AnthropicAdapter struct {
+	client    *http.Client
+	apiKey    string
+	endpoint  string
+	version   string
+	maxTokens int
+	models    map[ai.ModelId]string
+}
+
+// AnthropicAdapterOpts represents options for configuring the Anthropic adapter
+type AnthropicAdapterOpts = func(*AnthropicAdapter)
+
+func WithEndpoint(endpoint string) AnthropicAdapterOpts {
+	return func(a *AnthropicAdapter) {
+		a.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+func WithHTTPClient(client *http.Client) AnthropicAdapterOpts {
+	return func(a *AnthropicAdapter) {
+		a.client = client
+	}
+}
+
+// WithVersion sets the value of the anthropic-version header
+func WithVersion(version string) AnthropicAdapterOpts {
+	return func(a *AnthropicAdapter) {
+		a.version = version
+	}
+}
+
+// WithDefaultMaxTokens sets max_tokens for requests that don't set MaxCompletionTokens,
+// the Messages API requires it on every request.
+func WithDefaultMaxTokens(maxTokens int) AnthropicAdapterOpts {
+	return func(a *AnthropicAdapter) {
+		a.maxTokens = maxTokens
+	}
+}
+
+// WithModel maps a model id to the Anthropic model name
+func WithModel(model ai.ModelId, name string) AnthropicAdapterOpts {
+	return func(a *AnthropicAdapter) {
+		a.models[model] = name
+	}
+}
+
+// NewAnthropicAdapter creates a new Anthropic adapter with the given API key and options
+func NewAnthropicAdapter(apiKey string, opts ...AnthropicAdapterOpts) *AnthropicAdapter {
+	adapter := &AnthropicAdapter{
+		client:    http.DefaultClient,
+		apiKey:    apiKey,
+		endpoint:  DefaultEndpoint,
+		version:   DefaultVersion,
+		maxTokens: DefaultMaxTokens,
+		models:    make(map[ai.ModelId]string),
+	}
+
+	for model, name := range DefaultModels {
+		adapter.models[model] = name
+	}
+
+	for _, opt := range opts {
+		opt(adapter)
+	}
+
+	return adapter
+}
+
+// Invoke implements the LLM interface by calling Anthropic's Messages API
+func (a *AnthropicAdapter) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai.LLMResponse, error) {
+	messagesReq, err := a.newMessagesRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(messagesReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+
+	slog.Debug("request", "request", string(payload))
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", a.apiKey)
+	httpReq.Header.Set("anthropic-version", a.version)
+
+	httpResp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Anthropic API call failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	slog.Debug("response", "response", string(body))
+
+	if httpResp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("Anthropic API call failed: %s (%d): %s", apiErr.Error.Type, httpResp.StatusCode, apiErr.Error.Message)
+		}
+
+		return nil, fmt.Errorf("Anthropic API call failed: status %d: %s", httpResp.StatusCode, string(body))
+	}
+
+	var resp messagesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal response")
+	}
+
+	return a.convertResponse(&resp, request.Model), nil
+}
+
+func (a *AnthropicAdapter) newMessagesRequest(request *ai.LLMRequest) (*messagesRequest, error) {
+	system, messages, err := a.convertMessages(request.History)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert messages")
+	}
+
+	if request.System != "" {
+		system = append([]string{request.System}, system...)
+	}
+
+	messagesReq := &messagesRequest{
+		Model:     a.modelName(request.Model),
+		System:    strings.Join(system, "\n\n"),
+		Messages:  messages,
+		MaxTokens: a.maxTokens,
+	}
+
+	if request.MaxCompletionTokens > 0 {
+		messagesReq.MaxTokens = request.MaxCompletionTokens
+	}
+
+	if request.Temperature > 0 {
+		temperature := request.Temperature
+		messagesReq.Temperature = &temperature
+	}
+
+	// Handle tool usage based on the ToolUsage strategy
+	if request.ToolUsage != nil && len(request.Tools) > 0 {
+		messagesReq.Tools = a.convertTools(request.Tools)
+
+		toolChoice, err := convertToolUsage(request.ToolUsage, request.Tools)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert tool usage: %w", err)
+		}
+
+		messagesReq.ToolChoice = toolChoice
+	}
+
+	return messagesReq, nil
+}
+
+func (a *AnthropicAdapter) modelName(model ai.ModelId) string {
+	if name, ok := a.models[model]; ok {
+		return name
+	}
+
+	return string(model)
+}
+
+// convertMessages converts our Message interface to Anthropic's format.
+// System messages are returned separately since the Messages API only
+// accepts them as a top-level field.
+func (a *AnthropicAdapter) convertMessages(messages []ai.Message) ([]string, []message, error) {
+	var system []string
+	var anthropicMessages []message
+
+	// Messages API requires alternating roles, so consecutive blocks
+	// of the same role (e.g. several tool results) are merged together.
+	add := func(role string, block contentBlock) {
+		if n := len(anthropicMessages); n > 0 && anthropicMessages[n-1].Role == role {
+			anthropicMessages[n-1].Content = append(anthropicMessages[n-1].Content, block)
+			return
+		}
+
+		anthropicMessages = append(anthropicMessages, message{Role: role, Content: []contentBlock{block}})
+	}
+
+	for _, msg := range messages {
+		switch m := msg.(type) {
+		case *ai.TextMessage:
+			switch m.Role() {
+			case ai.MessageRoleUser:
+				add(roleUser, contentBlock{Type: blockTypeText, Text: m.Content})
+			case ai.MessageRoleAssistant:
+				add(roleAssistant, contentBlock{Type: blockTypeText, Text: m.Content})
+			case ai.MessageRoleSystem:
+				system = append(system, m.Content)
+			}
+
+		case *ai.MultiPartMessage:
+			// Messages API accepts images and documents from users only
+			if m.Role() != ai.MessageRoleUser {
+				for _, part := range m.Parts {
+					if part.Type != ai.ContentPartTypeText {
+						return nil, nil, fmt.Errorf("unsupported content part type in %s message: %s", m.Role(), part.Type)
+					}
+				}
+			}
+
+			if m.Role() == ai.MessageRoleSystem {
+				system = append(system, m.Text())
+				continue
+			}
+
+			role := roleUser
+			if m.Role() == ai.MessageRoleAssistant {
+				role = roleAssistant
+			}
+
+			for _, part := range m.Parts {
+
+				block, err := convertContentPart(part)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				add(role, block)
+			}
+
+		case *ai.ToolCallMessage:
+			input := m.ToolCall.Args
+			if len(input) == 0 {
+				input = json.RawMessage(`{}`)
+			}
+
+			add(roleAssistant, contentBlock{
+				Type:  blockTypeToolUse,
+				ID:    m.ToolCall.ID,
+				Name:  m.ToolCall.Name,
+				Input: input,
+			})
+
+		case *ai.ToolResultMessage:
+			block := contentBlock{
+				Type:      blockTypeToolResult,
+				ToolUseID: m.ToolCall.ID,
+				Content:   string(m.Result),
+			}
+
+			if m.Error != "" {
+				block.Content = m.Error
+				block.IsError = true
+			}
+
+			add(roleUser, block)
+
+		case *ai.ToolErrorMessage:
+			// Tool errors are reported through ToolResultMessage, same as in OpenAI adapter
+			continue
+		}
+	}
+
+	return system, anthropicMessages, nil
+}
+
+// convertContentPart converts a part of a multi-part message to an Anthropic content block
+func convertContentPart(part *ai.ContentPart) (contentBlock, error) {
+	switch part.Type {
+	case ai.ContentPartTypeText:
+		return contentBlock{Type: blockTypeText, Text: part.Text}, nil
+
+	case ai.ContentPartTypeImage:
+		return contentBlock{Type: blockTypeImage, Source: convertSource(part)}, nil
+
+	case ai.ContentPartTypeFile:
+		source := convertSource(part)
+		if part.IsTextFile() {
+			source = &blockSource{Type: "text", MediaType: "text/plain", Data: string(part.Data)}
+		}
+
+		return contentBlock{Type: blockTypeDocument, Source: source, Title: part.Filename}, nil
+
+	default:
+		return contentBlock{}, fmt.Errorf("unsupported content part type: %s", part.Type)
+	}
+}
+
+func convertSource(part *ai.ContentPart) *blockSource {
+	if len(part.Data) == 0 {
+		return &blockSource{Type: "url", URL: part.URL}
+	}
+
+	return &blockSource{
+		Type:      "base64",
+		MediaType: part.MIMEType,
+		Data:      base64.StdEncoding.EncodeToString(part.Data),
+	}
+}
+
+// convertTools converts our Tool interface to Anthropic's format
+func (a *AnthropicAdapter) convertTools(tools []tools.Tool) []tool {
+	var anthropicTools []tool
+
+	for _, t := range tools {
+		schema := t.InputSchemaRaw()
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type": "object"}`)
+		}
+
+		anthropicTools = append(anthropicTools, tool{
+			Name:        t.Name(),
+			Description: t.Description(),
+			InputSchema: schema,
+		})
+	}
+
+	return anthropicTools
+}
+
+func (a *AnthropicAdapter) convertResponse(resp *messagesResponse, model ai.ModelId) *ai.LLMResponse {
+	response := ai.NewLLMResponse()
+
+	for _, block := range resp.Content {
+		switch block.Type {
+		case blockTypeText:
+			if block.Text != "" {
+				response.AddMessage(ai.NewAssistantMessage(block.Text))
+			}
+
+		case blockTypeToolUse:
+			response.AddToolCall(&tools.ToolCall{
+				ID:   block.ID,
+				Name: block.Name,
+				Args: block.Input,
+			})
+		}
+	}
+
+	promptTokens := resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens
+
+	usage := ai.NewLLMUsage(
+		promptTokens,
+		resp.Usage.OutputTokens,
+		promptTokens+resp.Usage.OutputTokens,
+	)
+	usage.CachedPromptTokens = resp.Usage.CacheReadInputTokens
+
+	response.SetUsage(usage.ForModel(model))
+
+	return response
+}
@@ -0,0 +1,274 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/suite"
+)
+
+type MessagesSuite struct {
+	suite.Suite
+
+	server   *httptest.Server
+	recorder *recorder
+}
+
+func TestMessagesSuite(t *testing.T) {
+	suite.Run(t, new(MessagesSuite))
+}
+
+func (s *MessagesSuite) SetupTest() {
+	s.recorder = &recorder{}
+	s.server = httptest.NewServer(s.recorder)
+}
+
+func (s *MessagesSuite) TearDownTest() {
+	s.server.Close()
+}
+
+type Req struct {
+	Name string `json:"name"`
+}
+
+type Res struct {
+	Response string `json:"response"`
+}
+
+var greetTool = tools.NewSimpleTool("greet", "Greet someone",
+	func(ctx context.Context, input *Req) (*Res, error) {
+		return &Res{Response: "Hello, " + input.Name + "!"}, nil
+	},
+)
+
+func (s *MessagesSuite) TestRequest() {
+	s.recorder.respond(http.StatusOK, `{
+		"id": "msg_1",
+		"role": "assistant",
+		"content": [{"type": "text", "text": "Done."}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 10, "output_tokens": 5}
+	}`)
+
+	toolCall := tools.NewToolCall("call_1", "greet", json.RawMessage(`{"name":"John"}`))
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithSystem("You greet people."),
+		ai.WithHistory(ai.NewHistory(
+			ai.NewSystemMessage("Be polite."),
+			ai.NewUserMessage("Greet John"),
+			ai.NewAssistantMessage("Sure."),
+			ai.NewToolCallMessage(toolCall),
+			ai.NewToolResultMessage(toolCall, json.RawMessage(`{"response":"Hello, John!"}`)),
+		)),
+		ai.WithTools(greetTool),
+		ai.WithTemperature(0.5),
+	))
+	s.Require().NoError(err)
+
+	s.Require().Len(s.recorder.requests, 1)
+	recorded := s.recorder.requests[0]
+
+	s.Equal("/messages", recorded.path)
+	s.Equal("secret", recorded.header.Get("x-api-key"))
+	s.Equal(DefaultVersion, recorded.header.Get("anthropic-version"))
+
+	s.JSONEq(`{
+		"model": "claude-sonnet-4-0",
+		"system": "You greet people.\n\nBe polite.",
+		"max_tokens": 4096,
+		"temperature": 0.5,
+		"messages": [
+			{"role": "user", "content": [{"type": "text", "text": "Greet John"}]},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Sure."},
+				{"type": "tool_use", "id": "call_1", "name": "greet", "input": {"name": "John"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "call_1", "content": "{\"response\":\"Hello, John!\"}"}
+			]}
+		],
+		"tools": [
+			{"name": "greet", "description": "Greet someone", "input_schema": `+string(greetTool.InputSchemaRaw())+`}
+		],
+		"tool_choice": {"type": "auto"}
+	}`, string(recorded.body))
+}
+
+func (s *MessagesSuite) TestToolResultError() {
+	s.recorder.respond(http.StatusOK, `{"content": [], "usage": {}}`)
+
+	toolCall := tools.NewToolCall("call_1", "greet", json.RawMessage(`{"name":"John"}`))
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(
+			ai.NewToolCallMessage(toolCall),
+			ai.NewToolResultErrorMessage(toolCall, "boom"),
+		)),
+	))
+	s.Require().NoError(err)
+
+	var body messagesRequest
+	s.Require().NoError(json.Unmarshal(s.recorder.requests[0].body, &body))
+	s.Require().Len(body.Messages, 2)
+	s.Equal(contentBlock{Type: blockTypeToolResult, ToolUseID: "call_1", Content: "boom", IsError: true}, body.Messages[1].Content[0])
+}
+
+func (s *MessagesSuite) TestMultiPartMessage() {
+	s.recorder.respond(http.StatusOK, `{"content": [], "usage": {}}`)
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(
+			ai.NewUserMultiPartMessage(
+				ai.NewTextPart("Why did the error rate spike?"),
+				ai.NewImageURLPart("https://example.com/dashboard.png"),
+				ai.NewFilePart([]byte("ts,errors"), "text/csv", "errors.csv"),
+				ai.NewFilePart([]byte("pdf"), "application/pdf", "runbook.pdf"),
+			),
+		)),
+	))
+	s.Require().NoError(err)
+
+	var body map[string]any
+	s.Require().NoError(json.Unmarshal(s.recorder.requests[0].body, &body))
+
+	payload, err := json.Marshal(body["messages"])
+	s.Require().NoError(err)
+
+	s.JSONEq(`[{"role": "user", "content": [
+		{"type": "text", "text": "Why did the error rate spike?"},
+		{"type": "image", "source": {"type": "url", "url": "https://example.com/dashboard.png"}},
+		{"type": "document", "title": "errors.csv", "source": {"type": "text", "media_type": "text/plain", "data": "ts,errors"}},
+		{"type": "document", "title": "runbook.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "cGRm"}}
+	]}]`, string(payload))
+}
+
+func (s *MessagesSuite) TestAssistantMultiPartMessage() {
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(
+			ai.NewUserMessage("Show me the dashboard"),
+			ai.NewMultiPartMessage(ai.MessageRoleAssistant,
+				ai.NewTextPart("Here it is"),
+				ai.NewImageURLPart("https://example.com/dashboard.png"),
+			),
+		)),
+	))
+	s.Require().ErrorContains(err, "unsupported content part type in assistant message: image")
+
+	_, err = adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(
+			ai.NewMultiPartMessage(ai.MessageRoleSystem, ai.NewFilePart([]byte("pdf"), "application/pdf", "runbook.pdf")),
+			ai.NewUserMessage("Follow the runbook"),
+		)),
+	))
+	s.Require().ErrorContains(err, "unsupported content part type in system message: file")
+	s.Empty(s.recorder.requests)
+}
+
+func (s *MessagesSuite) TestForcedToolUsage() {
+	s.recorder.respond(http.StatusOK, `{"content": [], "usage": {}}`)
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL), WithModel(ai.Claude4Sonnet, "claude-opus-4-1"))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+		ai.WithTools(greetTool),
+		ai.WithToolUsage(tools.ForceTool("greet")),
+		ai.WithMaxCompletionTokens(100),
+	))
+	s.Require().NoError(err)
+
+	var body messagesRequest
+	s.Require().NoError(json.Unmarshal(s.recorder.requests[0].body, &body))
+	s.Equal("claude-opus-4-1", body.Model)
+	s.Equal(100, body.MaxTokens)
+	s.Equal(&toolChoice{Type: "tool", Name: "greet"}, body.ToolChoice)
+}
+
+func (s *MessagesSuite) TestResponse() {
+	s.recorder.respond(http.StatusOK, `{
+		"content": [
+			{"type": "text", "text": "Let me greet John."},
+			{"type": "tool_use", "id": "toolu_1", "name": "greet", "input": {"name": "John"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 3}
+	}`)
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	res, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+	))
+	s.Require().NoError(err)
+
+	s.Require().Len(res.Messages, 2)
+	s.Equal(ai.NewAssistantMessage("Let me greet John."), res.Messages[0])
+	s.Equal(
+		ai.NewToolCallMessage(tools.NewToolCall("toolu_1", "greet", json.RawMessage(`{"name": "John"}`))),
+		res.Messages[1],
+	)
+
+	s.Equal(int64(13), res.Usage.PromptTokens)
+	s.Equal(int64(5), res.Usage.CompletionTokens)
+	s.Equal(int64(18), res.Usage.TotalTokens)
+}
+
+func (s *MessagesSuite) TestError() {
+	s.recorder.respond(http.StatusBadRequest, `{
+		"type": "error",
+		"error": {"type": "invalid_request_error", "message": "max_tokens: field required"}
+	}`)
+
+	adapter := NewAnthropicAdapter("secret", WithEndpoint(s.server.URL))
+	_, err := adapter.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+	))
+
+	s.Require().Error(err)
+	s.Contains(err.Error(), "invalid_request_error")
+	s.Contains(err.Error(), "max_tokens: field required")
+}
+
+// recorder is an http.Handler that records incoming requests and replies with a canned response
+type recorder struct {
+	mu       sync.Mutex
+	requests []*recordedRequest
+
+	status int
+	body   string
+}
+
+type recordedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func (r *recorder) respond(status int, body string) {
+	r.status = status
+	r.body = body
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, &recordedRequest{path: req.URL.Path, header: req.Header, body: body})
+	r.mu.Unlock()
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(r.status)
+	w.Write([]byte(r.body))
+}
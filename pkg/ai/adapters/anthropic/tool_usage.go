@@ -0,0 +1,34 @@
+package anthropic
+
+import (
+	"fmt"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// convertToolUsage converts our ToolUsage interface to Anthropic's tool_choice format
+// Returns nil when no specific tool choice is needed (auto/default behavior)
+func convertToolUsage(toolUsage tools.ToolUsage, tools_ tools.Toolbox) (*toolChoice, error) {
+	switch toolUsage.Type() {
+	default:
+		return nil, nil
+
+	case tools.ToolUsageAuto:
+		return &toolChoice{Type: "auto"}, nil
+
+	case tools.ToolUsageNone:
+		return &toolChoice{Type: "none"}, nil
+
+	case tools.ToolUsageForced:
+		if forced, ok := toolUsage.(*tools.ForcedToolUsage); ok {
+			tool, err := tools_.FindTool(forced.ToolName)
+			if err != nil {
+				return nil, fmt.Errorf("forced tool %s not available", forced.ToolName)
+			}
+
+			return &toolChoice{Type: "tool", Name: tool.Name()}, nil
+		}
+
+		return nil, nil
+	}
+}
@@ -47,7 +47,83 @@ func NewOpenAIAdapter(apiKey string, opts ...OpenAIAdapterOpts) *OpenAIAdapter {
 
 // Invoke implements the LLM interface by calling OpenAI's API
 func (a *OpenAIAdapter) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai.LLMResponse, error) {
+	chatReq, err := a.newChatRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.MarshalIndent(chatReq, "", "  ")
+	slog.Debug("request", "request", string(payload))
+	resp, err := a.client.Chat.Completions.New(ctx, *chatReq, option.WithBaseURL(a.endpoint))
+
+	payload, _ = json.MarshalIndent(resp, "", "  ")
+	slog.Debug("response", "response", string(payload))
+
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API call failed: %w", err)
+	}
+
+	return a.convertResponse(resp, request.Model), nil
+}
+
+// InvokeStream implements the StreamingLLM interface using the chat completions stream.
+// Deltas are passed to the handler as they arrive, the accumulated completion is returned
+// as a regular response once the stream is finished.
+func (a *OpenAIAdapter) InvokeStream(ctx context.Context, request *ai.LLMRequest, handler ai.StreamHandler) (*ai.LLMResponse, error) {
+	chatReq, err := a.newChatRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	chatReq.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	payload, _ := json.MarshalIndent(chatReq, "", "  ")
+	slog.Debug("request", "request", string(payload))
+
+	stream := a.client.Chat.Completions.NewStreaming(ctx, *chatReq, option.WithBaseURL(a.endpoint))
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		if !acc.AddChunk(chunk) {
+			return nil, fmt.Errorf("OpenAI stream failed: could not accumulate chunk %s", chunk.ID)
+		}
+
+		if len(chunk.Choices) > 0 {
+			delta := chunk.Choices[0].Delta
+			if delta.Content != "" {
+				handler(ai.NewTextChunk(delta.Content))
+			}
+
+			for _, toolCall := range delta.ToolCalls {
+				handler(ai.NewToolCallChunk(&ai.ToolCallDelta{
+					Index: int(toolCall.Index),
+					ID:    toolCall.ID,
+					Name:  toolCall.Function.Name,
+					Args:  toolCall.Function.Arguments,
+				}))
+			}
+		}
+
+		if chunk.JSON.Usage.Valid() && chunk.Usage.TotalTokens > 0 {
+			handler(ai.NewUsageChunk(convertUsage(chunk.Usage, request.Model)))
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("OpenAI API call failed: %w", err)
+	}
+
+	payload, _ = json.MarshalIndent(acc.ChatCompletion, "", "  ")
+	slog.Debug("response", "response", string(payload))
 
+	return a.convertResponse(&acc.ChatCompletion, request.Model), nil
+}
+
+func (a *OpenAIAdapter) newChatRequest(request *ai.LLMRequest) (*openai.ChatCompletionNewParams, error) {
 	history := request.History
 
 	if request.System != "" {
@@ -59,7 +135,7 @@ func (a *OpenAIAdapter) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai
 		return nil, errors.Wrap(err, "failed to convert messages")
 	}
 
-	chatReq := openai.ChatCompletionNewParams{
+	chatReq := &openai.ChatCompletionNewParams{
 		Model:    shared.ChatModel(request.Model),
 		Messages: messages,
 	}
@@ -88,25 +164,13 @@ func (a *OpenAIAdapter) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai
 		}
 	}
 
-	payload, _ := json.MarshalIndent(chatReq, "", "  ")
-	slog.Debug("request", "request", string(payload))
-	resp, err := a.client.Chat.Completions.New(ctx, chatReq, option.WithBaseURL(a.endpoint))
-
-	payload, _ = json.MarshalIndent(resp, "", "  ")
-	slog.Debug("response", "response", string(payload))
-
-	if err != nil {
-		return nil, fmt.Errorf("OpenAI API call failed: %w", err)
-	}
+	return chatReq, nil
+}
 
+// convertResponse converts OpenAI's completion to our response
+func (a *OpenAIAdapter) convertResponse(resp *openai.ChatCompletion, model ai.ModelId) *ai.LLMResponse {
 	response := ai.NewLLMResponse()
 
-	usage := ai.NewLLMUsage(
-		resp.Usage.PromptTokens,
-		resp.Usage.CompletionTokens,
-		resp.Usage.TotalTokens,
-	)
-
 	if len(resp.Choices) > 0 {
 		choice := resp.Choices[0]
 		if choice.Message.Content != "" {
@@ -126,8 +190,19 @@ func (a *OpenAIAdapter) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai
 		}
 	}
 
-	response.SetUsage(usage)
-	return response, nil
+	response.SetUsage(convertUsage(resp.Usage, model))
+	return response
+}
+
+func convertUsage(usage openai.CompletionUsage, model ai.ModelId) *ai.LLMUsage {
+	converted := ai.NewLLMUsage(
+		usage.PromptTokens,
+		usage.CompletionTokens,
+		usage.TotalTokens,
+	)
+	converted.CachedPromptTokens = usage.PromptTokensDetails.CachedTokens
+
+	return converted.ForModel(model)
 }
 
 // convertMessages converts our Message interface to OpenAI's format
@@ -146,6 +221,14 @@ func (a *OpenAIAdapter) convertMessages(messages []ai.Message) ([]openai.ChatCom
 				openaiMessages = append(openaiMessages, openai.SystemMessage(m.Content))
 			}
 
+		case *ai.MultiPartMessage:
+			converted, err := convertMultiPartMessage(m)
+			if err != nil {
+				return nil, err
+			}
+
+			openaiMessages = append(openaiMessages, converted)
+
 		case *ai.ToolCallMessage:
 			// Convert tool call to assistant message with tool_calls
 			asst := openai.ChatCompletionAssistantMessageParam{
@@ -189,6 +272,60 @@ func (a *OpenAIAdapter) convertMessages(messages []ai.Message) ([]openai.ChatCom
 	return openaiMessages, nil
 }
 
+// convertMultiPartMessage converts a multi-part message to OpenAI's content parts. Only user
+// messages can carry images and files, other roles get the text parts only.
+func convertMultiPartMessage(m *ai.MultiPartMessage) (openai.ChatCompletionMessageParamUnion, error) {
+	// Images and files are accepted from users only
+	if m.Role() != ai.MessageRoleUser {
+		for _, part := range m.Parts {
+			if part.Type != ai.ContentPartTypeText {
+				return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("unsupported content part type in %s message: %s", m.Role(), part.Type)
+			}
+		}
+	}
+
+	switch m.Role() {
+	case ai.MessageRoleAssistant:
+		return openai.AssistantMessage(m.Text()), nil
+	case ai.MessageRoleSystem:
+		return openai.SystemMessage(m.Text()), nil
+	}
+
+	var parts []openai.ChatCompletionContentPartUnionParam
+	for _, part := range m.Parts {
+		switch part.Type {
+		case ai.ContentPartTypeText:
+			parts = append(parts, openai.TextContentPart(part.Text))
+
+		case ai.ContentPartTypeImage:
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: part.DataURL(),
+			}))
+
+		case ai.ContentPartTypeFile:
+			// Textual files are passed inline, file parts only support documents like PDF
+			if part.IsTextFile() {
+				parts = append(parts, openai.TextContentPart(part.TextFileContent()))
+				continue
+			}
+
+			if len(part.Data) == 0 {
+				return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("file %s has no inline data, file URLs are not supported", part.Filename)
+			}
+
+			parts = append(parts, openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+				FileData: openai.String(part.DataURL()),
+				Filename: openai.String(part.Filename),
+			}))
+
+		default:
+			return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("unsupported content part type: %s", part.Type)
+		}
+	}
+
+	return openai.UserMessage(parts), nil
+}
+
 // convertTools converts our Tool interface to OpenAI's format
 func (a *OpenAIAdapter) convertTools(tools []tools.Tool) []openai.ChatCompletionToolUnionParam {
 	var openaiTools []openai.ChatCompletionToolUnionParam
@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/prompts"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/require"
+)
+
+var streamChunks = []string{
+	`{"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":", John"}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"greet","arguments":""}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"name\":"}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"John\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	`{"id":"1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+}
+
+func TestInvokeStream(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &requestBody))
+
+		w.Header().Set("content-type", "text/event-stream")
+		for _, chunk := range streamChunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var chunks []*ai.LLMChunk
+
+	adapter := NewOpenAIAdapter("secret", WithEndpoint(server.URL))
+	res, err := adapter.InvokeStream(context.Background(), ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+	), func(chunk *ai.LLMChunk) {
+		chunks = append(chunks, chunk)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, true, requestBody["stream"])
+	require.Equal(t, map[string]any{"include_usage": true}, requestBody["stream_options"])
+
+	require.Equal(t, []*ai.LLMChunk{
+		ai.NewTextChunk("Hello"),
+		ai.NewTextChunk(", John"),
+		ai.NewToolCallChunk(&ai.ToolCallDelta{Index: 0, ID: "call_1", Name: "greet"}),
+		ai.NewToolCallChunk(&ai.ToolCallDelta{Index: 0, Args: `{"name":`}),
+		ai.NewToolCallChunk(&ai.ToolCallDelta{Index: 0, Args: `"John"}`}),
+		ai.NewUsageChunk(ai.NewLLMUsage(10, 5, 15).ForModel(ai.Claude4Sonnet)),
+	}, chunks)
+
+	require.Equal(t, ai.History{
+		ai.NewAssistantMessage("Hello, John"),
+		ai.NewToolCallMessage(tools.NewToolCall("call_1", "greet", json.RawMessage(`{"name":"John"}`))),
+	}, res.Messages)
+	require.Equal(t, ai.NewLLMUsage(10, 5, 15).ForModel(ai.Claude4Sonnet), res.Usage)
+}
+
+func TestConvertMultiPartMessage(t *testing.T) {
+	message := prompts.NewPromptBuilder().
+		AddBlock("Why did the error rate spike?", prompts.WithTitle("Question")).
+		AddImage([]byte("png"), "image/png", prompts.WithTitle("Dashboard")).
+		AddFile([]byte("ts,errors\n1,10"), "text/csv", "errors.csv").
+		AddFile([]byte("pdf"), "application/pdf", "runbook.pdf").
+		BuildMultiPartUserMessage()
+
+	adapter := NewOpenAIAdapter("secret")
+	converted, err := adapter.convertMessages(ai.NewHistory(message))
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(converted)
+	require.NoError(t, err)
+
+	require.JSONEq(t, `[{"role": "user", "content": [
+		{"type": "text", "text": "# Question\n\nWhy did the error rate spike?\n\n# Dashboard"},
+		{"type": "image_url", "image_url": {"url": "data:image/png;base64,cG5n"}},
+		{"type": "text", "text": "File: errors.csv\n`+"```"+`\nts,errors\n1,10\n`+"```"+`"},
+		{"type": "file", "file": {"file_data": "data:application/pdf;base64,cGRm", "filename": "runbook.pdf"}}
+	]}]`, string(payload))
+}
+
+func TestConvertMultiPartMessageFromAssistant(t *testing.T) {
+	adapter := NewOpenAIAdapter("secret")
+
+	converted, err := adapter.convertMessages(ai.NewHistory(ai.NewMultiPartMessage(ai.MessageRoleAssistant, ai.NewTextPart("Here it is"))))
+	require.NoError(t, err)
+	require.Len(t, converted, 1)
+
+	_, err = adapter.convertMessages(ai.NewHistory(ai.NewMultiPartMessage(ai.MessageRoleAssistant,
+		ai.NewTextPart("Here it is"),
+		ai.NewImageURLPart("https://example.com/dashboard.png"),
+	)))
+	require.ErrorContains(t, err, "unsupported content part type in assistant message: image")
+
+	_, err = adapter.convertMessages(ai.NewHistory(ai.NewMultiPartMessage(ai.MessageRoleSystem,
+		ai.NewFilePart([]byte("pdf"), "application/pdf", "runbook.pdf"),
+	)))
+	require.ErrorContains(t, err, "unsupported content part type in system message: file")
+}
@@ -17,6 +17,12 @@ func convertToolUsage(toolUsage tools.ToolUsage, tools_ tools.Toolbox) (*openai.
 	case tools.ToolUsageAuto:
 		return nil, nil
 
+	case tools.ToolUsageNone:
+		toolChoice := openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfAuto: openai.String(string(openai.ChatCompletionToolChoiceOptionAutoNone)),
+		}
+		return &toolChoice, nil
+
 	case tools.ToolUsageForced:
 		if forced, ok := toolUsage.(*tools.ForcedToolUsage); ok {
 			tool, err := tools_.FindTool(forced.ToolName)
@@ -44,6 +44,12 @@ func TestConvertToolUsage(t *testing.T) {
 			tools:     []tools.Tool{&mockTool{name: "calculator"}},
 			expectNil: false,
 		},
+		{
+			name:      "No tool selection should return tool choice",
+			toolUsage: tools.NoToolSelection(),
+			tools:     []tools.Tool{&mockTool{name: "calculator"}},
+			expectNil: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -3,7 +3,10 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	_ "embed"
 
@@ -21,7 +24,30 @@ type Agent struct {
 
 	events *ai.MultiplexEvents
 
-	totalUsage *ai.LLMUsage
+	streaming bool
+
+	// maximum number of tool calls of one turn executed at the same time
+	toolConcurrency int
+
+	// budgets, zero means unlimited
+	maxTurns       int
+	maxTokens      int64
+	maxToolCalls   int
+	maxDuration    time.Duration
+	budgetStrategy BudgetStrategy
+
+	// decides over calls of tools requiring approval
+	approver Approver
+
+	// tool calls of a suspended run, executed before the first turn
+	resume []*tools.ToolCall
+
+	// shrink tool results before they go into the history
+	resultPolicies     []ResultPolicy
+	toolResultPolicies map[string][]ResultPolicy
+
+	// tools available through the search_tools meta-tool only
+	toolSearch *toolSearch
 }
 
 // AgentOpts represents options for configuring an agent
@@ -40,12 +66,30 @@ func WithEvents(events ai.AgentEvents) AgentOpts {
 	}
 }
 
+// WithStreaming makes the agent stream completions when the LLM implements ai.StreamingLLM,
+// forwarding deltas to OnTextDelta and OnToolCallDelta events
+func WithStreaming() AgentOpts {
+	return func(a *Agent) {
+		a.streaming = true
+	}
+}
+
+// WithParallelToolCalls executes tool calls of one turn concurrently, running at most
+// limit calls at the same time. Limit of 0 or less runs all calls of the turn at once.
+// Results are still added to the history in the order the model emitted the calls.
+func WithParallelToolCalls(limit int) AgentOpts {
+	return func(a *Agent) {
+		a.toolConcurrency = limit
+	}
+}
+
 // NewAgent creates a new agent with the given LLM and tools
 func NewAgent(llm_ ai.LLM, opts ...AgentOpts) ai.LLM {
 	a := &Agent{
-		llm:        llm_,
-		events:     ai.NewMultiplexEvents(),
-		totalUsage: ai.NewLLMUsage(0, 0, 0),
+		llm:             llm_,
+		events:          ai.NewMultiplexEvents(),
+		toolConcurrency: 1,
+		budgetStrategy:  BudgetStrategyError,
 	}
 
 	// Apply options first to set up events
@@ -61,72 +105,298 @@ func NewAgent(llm_ ai.LLM, opts ...AgentOpts) ai.LLM {
 	return a
 }
 
-// Loop processes the conversation loop, handling tool calls and LLM responses
+// Invoke runs the conversation loop, calling tools requested by the LLM and feeding
+// their results back until the LLM answers without tool calls or a budget runs out.
+// Usage of the response covers this invocation only, broken down per turn.
 func (a *Agent) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai.LLMResponse, error) {
-	// Check if context is already cancelled
-	if err := ctx.Err(); err != nil {
-		return nil, err
-	}
+	state := newRunState()
+	ctx = withRunState(ctx, state)
 
-	a.events.OnRequest(ctx, request)
+	// Messages produced during this invocation, returned with ErrBudgetExceeded
+	var produced ai.History
+	toolCallCount := 0
+	started := time.Now()
 
-	response, err := a.llm.Invoke(ctx, request)
-	if err != nil {
-		a.events.OnRequestError(ctx, request, err)
-		return nil, err
+	request = a.withSearchedTools(request)
+
+	// Run suspended for approval ends with tool calls, execute them before asking the model again
+	if len(a.resume) > 0 {
+		pending, err := resumedToolCalls(request.History, a.resume)
+		if err != nil {
+			return nil, err
+		}
+
+		messages, err := a.executeToolCalls(ctx, request, pending)
+		if err != nil {
+			return nil, a.suspended(err, request.History, state)
+		}
+
+		toolCallCount += len(pending)
+		a.events.OnResponse(ctx, request, ai.NewLLMResponse(messages...).SetUsage(state.snapshot()), false)
+
+		produced = produced.Append(messages...)
+		request = request.Clone(ai.WithHistory(request.History.Append(messages...)))
 	}
 
-	// a.events.OnResponse(ctx, request, response)
-	a.totalUsage.Add(response.Usage)
+	for turn := 0; ; turn++ {
+		// Check if context is already cancelled
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	if len(response.ToolCalls()) > 0 {
-		for _, toolCall := range response.ToolCalls() {
-			// Check for context cancellation before processing each tool call
-			if err := ctx.Err(); err != nil {
-				return nil, err
-			}
+		if exceeded := a.checkBudget(turn, state.snapshot(), started); exceeded != nil {
+			return a.onBudgetExceeded(ctx, request, produced, turn, exceeded)
+		}
 
-			a.events.OnToolCall(ctx, toolCall)
+		request = a.withSearchedTools(request)
+		a.events.OnRequest(ctx, request)
 
-			// Find the tool to get its input schema
-			targetTool, err := request.Tools.FindTool(toolCall.Name)
-			if err != nil {
-				return nil, err
-			}
+		response, err := a.invokeLLM(ctx, request)
+		if err != nil {
+			a.events.OnRequestError(ctx, request, err)
+			return nil, err
+		}
 
-			retrier := structured.NewRetrier(a.retryConfig, NewToolCallRetriable(a.llm, toolCall, targetTool, a.events))
+		state.addTurn(turn, request.Model, response.Usage)
 
-			message, err := retrier.Execute(ctx, a.llm)
-			if err != nil {
-				response.AddMessage(ai.NewToolResultErrorMessage(toolCall, err.Error()))
-				a.totalUsage.AddToolCall(toolCall, err)
-			} else {
-				response.AddMessage(message)
-				a.totalUsage.AddToolCall(toolCall, nil)
-			}
+		toolCalls := response.ToolCalls()
+		if len(toolCalls) == 0 {
+			// Return usage 'to-date' rather than just the last response's usage
+			response.SetUsage(state.snapshot())
+			a.events.OnResponse(ctx, request, response, true)
+
+			return response, nil
+		}
+
+		if exceeded := a.checkToolCallBudget(toolCallCount, len(toolCalls)); exceeded != nil {
+			// Tool calls of this turn won't be executed, keep only what the model said
+			kept := withoutToolCalls(response.Messages)
+			request = request.Clone(ai.WithHistory(request.History.Append(kept...)))
+
+			return a.onBudgetExceeded(ctx, request, produced.Append(kept...), turn+1, exceeded)
 		}
 
+		messages, err := a.executeToolCalls(ctx, request, toolCalls)
+		if err != nil {
+			return nil, a.suspended(err, request.History.Append(response.Messages...), state)
+		}
+
+		toolCallCount += len(toolCalls)
+		response.AddMessages(messages...)
+
 		// Return usage 'to-date' rather than just the last response's usage
-		response.SetUsage(a.totalUsage)
+		response.SetUsage(state.snapshot())
 		a.events.OnResponse(ctx, request, response, false)
 
-		req := request.Clone(
+		produced = produced.Append(response.Messages...)
+		request = request.Clone(
 			ai.WithHistory(request.History.Append(response.Messages...)),
 		)
+	}
+}
 
-		// Check for context cancellation before recursive call
-		if err := ctx.Err(); err != nil {
+// onBudgetExceeded either fails with the partial response, or asks the model for a final answer
+func (a *Agent) onBudgetExceeded(ctx context.Context, request *ai.LLMRequest, produced ai.History, turn int, exceeded *ErrBudgetExceeded) (*ai.LLMResponse, error) {
+	state, _ := runStateFrom(ctx)
+
+	if a.budgetStrategy != BudgetStrategyFinalAnswer {
+		exceeded.Response = ai.NewLLMResponse(produced...).SetUsage(state.snapshot())
+		return nil, exceeded
+	}
+
+	final := request.Clone(
+		ai.WithAddedHistory(ai.NewHistory(ai.NewUserMessage(finalAnswerPrompt))),
+		ai.WithToolUsage(tools.NoToolSelection()),
+	)
+
+	a.events.OnRequest(ctx, final)
+
+	response, err := a.invokeLLM(ctx, final)
+	if err != nil {
+		a.events.OnRequestError(ctx, final, err)
+		return nil, err
+	}
+
+	state.addTurn(turn, final.Model, response.Usage)
+
+	// Model may still try to call tools, those can't be executed anymore
+	response.Messages = withoutToolCalls(response.Messages)
+	response.SetUsage(state.snapshot())
+	a.events.OnResponse(ctx, final, response, true)
+
+	return response, nil
+}
+
+// suspended completes ErrApprovalRequired with the conversation to resume from, other errors pass through
+func (a *Agent) suspended(err error, history ai.History, state *runState) error {
+	var approval *ErrApprovalRequired
+	if errors.As(err, &approval) {
+		approval.Response = ai.NewLLMResponse(history...).SetUsage(state.snapshot())
+	}
+
+	return err
+}
+
+func withoutToolCalls(messages ai.History) ai.History {
+	var filtered ai.History
+	for _, message := range messages {
+		if message.Kind() != ai.MessageKindToolCall {
+			filtered = append(filtered, message)
+		}
+	}
+
+	return filtered
+}
+
+// executeToolCalls runs tool calls of a single turn, at most toolConcurrency at a time,
+// and returns their results in the order of the calls, followed by a message with attachments
+// of the results, if any. No call is executed while some wait for approval, the turn fails
+// with ErrApprovalRequired instead.
+func (a *Agent) executeToolCalls(ctx context.Context, request *ai.LLMRequest, toolCalls []*tools.ToolCall) ([]ai.Message, error) {
+	decisions, pending, err := a.approvals(ctx, request, toolCalls)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		return nil, &ErrApprovalRequired{Pending: pending}
+	}
+
+	limit := a.toolConcurrency
+	if limit <= 0 {
+		limit = len(toolCalls)
+	}
+
+	messages := make([]ai.Message, len(toolCalls))
+	attachments := make([][]*tools.Attachment, len(toolCalls))
+	errs := make([]error, len(toolCalls))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+
+	for i, toolCall := range toolCalls {
+		// Check for context cancellation before processing each tool call
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		if errs[i] != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			messages[i], attachments[i], errs[i] = a.executeToolCall(ctx, request, toolCall, decisions[toolCall.ID])
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
 			return nil, err
 		}
+	}
 
-		return a.Invoke(ctx, req)
+	if message := attachmentsMessage(toolCalls, attachments); message != nil {
+		messages = append(messages, message)
 	}
 
-	// Return usage 'to-date' rather than just the last response's usage
-	response.SetUsage(a.totalUsage)
-	a.events.OnResponse(ctx, request, response, true)
+	return messages, nil
+}
 
-	return response, nil
+// executeToolCall runs a single tool call with retries and returns its result together with
+// attachments of the result. Failing tools produce an error result for the model, only
+// a missing tool or cancelled context fails the call.
+func (a *Agent) executeToolCall(ctx context.Context, request *ai.LLMRequest, toolCall *tools.ToolCall, decision *tools.ApprovalDecision) (ai.Message, []*tools.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	toolCall, rejected, err := applyDecision(toolCall, decision)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rejected != nil {
+		return rejected, nil, nil
+	}
+
+	a.events.OnToolCall(ctx, toolCall)
+
+	// Find the tool to get its input schema
+	targetTool, err := request.Tools.FindTool(toolCall.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Tools report through the execution info how the call was served, e.g. from a cache
+	info := &tools.ExecutionInfo{}
+	ctx = tools.WithExecutionInfo(ctx, info)
+
+	retrier := structured.NewRetrier(a.retryConfig, NewToolCallRetriable(a.llm, toolCall, targetTool, a.events))
+
+	message, err := retrier.Execute(ctx, a.llm)
+	if state, ok := runStateFrom(ctx); ok {
+		state.addToolCall(toolCall, err, err == nil && info.Cached)
+	}
+
+	if err != nil {
+		return ai.NewToolResultErrorMessage(toolCall, err.Error()), nil, nil
+	}
+
+	reduced, err := a.reduceResult(ctx, toolCall, message)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+
+		return ai.NewToolResultErrorMessage(toolCall, err.Error()), nil, nil
+	}
+
+	return reduced, info.Attachments, nil
+}
+
+// attachmentsMessage passes attachments of tool results to the model in a message following
+// the results, as results themselves can only hold text. Returns nil without attachments.
+func attachmentsMessage(toolCalls []*tools.ToolCall, attachments [][]*tools.Attachment) ai.Message {
+	var parts []*ai.ContentPart
+	for i, toolCall := range toolCalls {
+		if len(attachments[i]) == 0 {
+			continue
+		}
+
+		parts = append(parts, ai.NewTextPart(fmt.Sprintf("Attachments of the %s result (call %s):", toolCall.Name, toolCall.ID)))
+		for _, attachment := range attachments[i] {
+			parts = append(parts, ai.NewAttachmentPart(attachment))
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	return ai.NewUserMultiPartMessage(parts...)
+}
+
+// invokeLLM calls the underlying LLM, streaming deltas to events if enabled and supported
+func (a *Agent) invokeLLM(ctx context.Context, request *ai.LLMRequest) (*ai.LLMResponse, error) {
+	streamingLLM, ok := a.llm.(ai.StreamingLLM)
+	if !a.streaming || !ok {
+		return a.llm.Invoke(ctx, request)
+	}
+
+	return streamingLLM.InvokeStream(ctx, request, func(chunk *ai.LLMChunk) {
+		switch chunk.Kind {
+		case ai.LLMChunkKindText:
+			a.events.OnTextDelta(ctx, request, chunk.Text)
+		case ai.LLMChunkKindToolCall:
+			a.events.OnToolCallDelta(ctx, request, chunk.ToolCall)
+		}
+	})
 }
 
 // RETRIABLE
@@ -144,12 +414,21 @@ func NewToolCallRetriable(llm ai.LLM, toolCall *tools.ToolCall, targetTool tools
 }
 
 func (t *ToolCallRetriable) Retry(ctx context.Context, attempt int) (ai.Message, error) {
+	// Attachments of failed attempts don't belong to the result
+	if info, ok := tools.ExecutionInfoFrom(ctx); ok {
+		info.Attachments = nil
+	}
+
 	result, err := t.targetTool.Execute(ctx, t.toolCall.Args)
 	if err != nil {
 		t.events.OnToolError(ctx, t.toolCall, attempt, err)
 		return nil, err
 	}
 
+	if info, ok := tools.ExecutionInfoFrom(ctx); ok && info.Cached {
+		t.events.OnToolCacheHit(ctx, t.toolCall, result)
+	}
+
 	t.events.OnToolResult(ctx, t.toolCall, result)
 	return ai.NewToolResultMessage(t.toolCall, result), nil
 }
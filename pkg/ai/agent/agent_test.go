@@ -4,6 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -117,6 +121,402 @@ func (s *AgentSuite) TestAgentWithFailingTool() {
 	s.Require().Equal(ai.NewAssistantMessage("Done."), res.Messages[0])
 }
 
+func (s *AgentSuite) TestAgentStreaming() {
+	llm := NewMockStreamingLLM()
+	llm.
+		On("InvokeStream", mock.Anything, mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Run(func(args mock.Arguments) {
+			handler := args.Get(2).(ai.StreamHandler)
+			handler(ai.NewTextChunk("Do"))
+			handler(ai.NewTextChunk("ne."))
+			handler(ai.NewUsageChunk(ai.NewLLMUsage(1, 1, 2)))
+		}).
+		Once()
+
+	events := &deltaEvents{NoopAgentEvents: ai.NewNoopAgentEvents()}
+
+	agent := NewAgent(llm, WithStreaming(), WithEvents(events))
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithHistory(ai.NewHistory(
+			ai.NewUserMessage("Say done"),
+		)),
+	))
+
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewAssistantMessage("Done."), res.Messages[0])
+	s.Require().Equal([]string{"Do", "ne."}, events.deltas)
+}
+
+type deltaEvents struct {
+	*ai.NoopAgentEvents
+	deltas []string
+}
+
+func (e *deltaEvents) OnTextDelta(ctx context.Context, request *ai.LLMRequest, delta string) {
+	e.deltas = append(e.deltas, delta)
+}
+
+func (s *AgentSuite) TestAgentParallelToolCalls() {
+	var running, maxRunning atomic.Int32
+
+	// Calls report when they start and block until released
+	started := make(chan int)
+	release := make([]chan struct{}, 6)
+	for i := range release {
+		release[i] = make(chan struct{})
+	}
+
+	slowTool := tools.NewSimpleTool("slow", "Slow greeting",
+		func(ctx context.Context, input *Req) (*Res, error) {
+			current := running.Add(1)
+			defer running.Add(-1)
+
+			for {
+				max := maxRunning.Load()
+				if current <= max || maxRunning.CompareAndSwap(max, current) {
+					break
+				}
+			}
+
+			index := len(input.Name) - 1
+			started <- index
+			<-release[index]
+
+			return &Res{Response: input.Name}, nil
+		},
+	)
+
+	// Wait until the limit of calls is running, then finish the latest one first
+	// to make sure results are still ordered by call
+	go func() {
+		var waiting []int
+		for startedCalls, done := 0, 0; done < 6; {
+			if len(waiting) < 3 && startedCalls < 6 {
+				select {
+				case index := <-started:
+					waiting = append(waiting, index)
+					startedCalls++
+				case <-time.After(5 * time.Second):
+					s.T().Error("tool calls did not start")
+					for _, index := range waiting {
+						close(release[index])
+					}
+					return
+				}
+				continue
+			}
+
+			slices.Sort(waiting)
+			close(release[waiting[len(waiting)-1]])
+			waiting = waiting[:len(waiting)-1]
+			done++
+		}
+	}()
+
+	var calls []ai.Message
+	for i := range 6 {
+		args := fmt.Sprintf(`{"name": "%s"}`, strings.Repeat("x", i+1))
+		calls = append(calls, ai.NewToolCallMessage(tools.NewToolCall(fmt.Sprint(i), "slow", json.RawMessage(args))))
+	}
+
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(calls...), nil).
+		Once()
+
+	var secondRequest *ai.LLMRequest
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			secondRequest = args.Get(1).(*ai.LLMRequest)
+		}).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	events := &countingEvents{NoopAgentEvents: ai.NewNoopAgentEvents()}
+
+	agent := NewAgent(llm, WithParallelToolCalls(3), WithEvents(events))
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet everyone"))),
+		ai.WithTools(slowTool),
+	))
+
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewAssistantMessage("Done."), res.Messages[0])
+	s.Require().Equal(int32(3), maxRunning.Load())
+	s.Require().Equal(int32(6), events.toolResults.Load())
+	s.Require().Len(res.Usage.ToolCalls, 6)
+
+	// user message, 6 tool calls, 6 results in the original call order
+	s.Require().Len(secondRequest.History, 13)
+	for i := range 6 {
+		result, ok := secondRequest.History[7+i].(*ai.ToolResultMessage)
+		s.Require().True(ok)
+		s.Require().Equal(fmt.Sprint(i), result.ToolCall.ID)
+	}
+}
+
+func (s *AgentSuite) TestAgentUsagePerInvoke() {
+	llm := NewMockLLM()
+	for range 2 {
+		llm.
+			On("Invoke", mock.Anything, mock.Anything).
+			Return(ai.NewLLMResponse(
+				ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+			).SetUsage(ai.NewLLMUsage(10, 2, 12)), nil).
+			Once()
+
+		llm.
+			On("Invoke", mock.Anything, mock.Anything).
+			Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")).SetUsage(ai.NewLLMUsage(20, 3, 23)), nil).
+			Once()
+	}
+
+	agent := NewAgent(llm)
+	request := ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+		ai.WithTools(greetTool),
+	)
+
+	for range 2 {
+		res, err := agent.Invoke(context.Background(), request)
+		s.Require().NoError(err)
+
+		s.Require().Equal(int64(35), res.Usage.TotalTokens)
+		s.Require().Equal(int64(2), res.Usage.Turns)
+		s.Require().Len(res.Usage.ToolCalls, 1)
+		s.Require().Equal([]*ai.LLMUsageTurn{
+			{Turn: 0, Model: ai.Claude4Sonnet, LLMUsageTokens: ai.LLMUsageTokens{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}, ToolCalls: 1},
+			{Turn: 1, Model: ai.Claude4Sonnet, LLMUsageTokens: ai.LLMUsageTokens{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23}},
+		}, res.Usage.Breakdown)
+	}
+}
+
+func (s *AgentSuite) TestAgentMaxTurns() {
+	llm := NewMockLLM()
+	for i := range 2 {
+		llm.
+			On("Invoke", mock.Anything, mock.Anything).
+			Return(ai.NewLLMResponse(
+				ai.NewToolCallMessage(tools.NewToolCall(fmt.Sprint(i), "greet", json.RawMessage(`{"name": "John"}`))),
+			), nil).
+			Once()
+	}
+
+	agent := NewAgent(llm, WithMaxTurns(2))
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John forever"))),
+		ai.WithTools(greetTool),
+	))
+
+	s.Require().Nil(res)
+
+	var exceeded *ErrBudgetExceeded
+	s.Require().ErrorAs(err, &exceeded)
+	s.Require().Equal(BudgetTurns, exceeded.Budget)
+	s.Require().Equal(int64(2), exceeded.Limit)
+
+	// two turns of tool call and result
+	s.Require().Len(exceeded.Response.Messages, 4)
+	s.Require().Len(exceeded.Response.Usage.ToolCalls, 2)
+	llm.AssertExpectations(s.T())
+}
+
+func (s *AgentSuite) TestAgentMaxToolCallsFinalAnswer() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+		), nil).
+		Once()
+
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewAssistantMessage("Let me greet more people."),
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "Tom"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("3", "greet", json.RawMessage(`{"name": "Ann"}`))),
+		), nil).
+		Once()
+
+	var final *ai.LLMRequest
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			final = args.Get(1).(*ai.LLMRequest)
+		}).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Greeted John.")), nil).
+		Once()
+
+	agent := NewAgent(llm, WithMaxToolCalls(2), WithBudgetStrategy(BudgetStrategyFinalAnswer))
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet everyone"))),
+		ai.WithTools(greetTool),
+	))
+
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewAssistantMessage("Greeted John."), res.Messages[0])
+	s.Require().Len(res.Usage.ToolCalls, 1)
+
+	s.Require().Equal(tools.ToolUsageNone, final.ToolUsage.Type())
+	s.Require().Equal(ai.History{
+		ai.NewUserMessage("Greet everyone"),
+		ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+		ai.NewToolResultMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`)), json.RawMessage(`{"response":"Hello, John!"}`)),
+		ai.NewAssistantMessage("Let me greet more people."),
+		ai.NewUserMessage(finalAnswerPrompt),
+	}, final.History)
+}
+
+// countingEvents counts atomically, events of concurrent tool calls are delivered concurrently
+type countingEvents struct {
+	*ai.NoopAgentEvents
+	toolResults atomic.Int32
+	cacheHits   atomic.Int32
+	responses   atomic.Int32
+}
+
+func (e *countingEvents) OnResponse(ctx context.Context, request *ai.LLMRequest, response *ai.LLMResponse, terminal bool) {
+	e.responses.Add(1)
+}
+
+func (e *countingEvents) OnToolResult(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
+	e.toolResults.Add(1)
+}
+
+func (e *countingEvents) OnToolCacheHit(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
+	e.cacheHits.Add(1)
+}
+
+func (s *AgentSuite) TestAgentCachedToolCalls() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{ "name":"John" }`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	events := &countingEvents{NoopAgentEvents: ai.NewNoopAgentEvents()}
+	agent := NewAgent(llm, WithEvents(events))
+
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John twice"))),
+		ai.WithTools(tools.NewCachedTool(greetTool, tools.NewMemoryToolCache())),
+	))
+	s.Require().NoError(err)
+
+	s.Require().Equal(int32(2), events.toolResults.Load())
+	s.Require().Equal(int32(1), events.cacheHits.Load())
+	s.Require().Len(res.Usage.ToolCalls, 2)
+	s.Require().False(res.Usage.ToolCalls[0].Cached)
+	s.Require().True(res.Usage.ToolCalls[1].Cached)
+	s.Require().Equal(1, res.Usage.CachedToolCalls())
+}
+
+func (s *AgentSuite) TestAgentApprovalSuspendsAndResumes() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+		), nil).
+		Once()
+
+	request := ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+		ai.WithTools(tools.RequireApproval(greetTool)),
+	)
+
+	// Without a decision the agent suspends before executing the call
+	_, err := NewAgent(llm).Invoke(context.Background(), request)
+
+	var suspended *ErrApprovalRequired
+	s.Require().ErrorAs(err, &suspended)
+	s.Require().Equal([]*tools.ToolCall{tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))}, suspended.Pending)
+	s.Require().Len(suspended.Response.Messages, 2)
+	s.Require().Empty(suspended.Response.Usage.ToolCalls)
+
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Twice()
+
+	approver := func(ctx context.Context, toolCall *tools.ToolCall) (*tools.ApprovalDecision, error) {
+		return tools.Approve(), nil
+	}
+	events := &countingEvents{NoopAgentEvents: ai.NewNoopAgentEvents()}
+	resumedRequest := request.Clone(ai.WithHistory(suspended.Response.Messages))
+
+	// Tool calls a history ends with aren't executed unless the run is resumed explicitly
+	_, err = NewAgent(llm, WithApprover(approver)).Invoke(context.Background(), resumedRequest)
+	s.Require().NoError(err)
+	s.Require().Len(llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest).History, 2)
+
+	// Resuming the suspended run executes the approved call first
+	res, err := NewAgent(llm, WithApprover(approver), WithResume(suspended.Pending), WithEvents(events)).Invoke(context.Background(), resumedRequest)
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewAssistantMessage("Done."), res.Messages.Last())
+	s.Require().Equal(int32(2), events.responses.Load())
+
+	resumed := llm.Calls[2].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Len(resumed.History, 3)
+	s.Require().JSONEq(`{"response":"Hello, John!"}`, string(resumed.History[2].(*ai.ToolResultMessage).Result))
+	llm.AssertExpectations(s.T())
+
+	// Resumed history has to end with the pending calls
+	_, err = NewAgent(llm, WithResume(suspended.Pending)).Invoke(context.Background(), request)
+	s.Require().ErrorContains(err, "can't resume, history doesn't end with the pending tool calls 1")
+}
+
+func (s *AgentSuite) TestAgentApproverDecisions() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "greet", json.RawMessage(`{"name": "John"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "Tom"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("3", "greet", json.RawMessage(`{"name": "Ann"}`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	decisions := map[string]*tools.ApprovalDecision{
+		"1": tools.Approve(),
+		"2": tools.Reject("Tom asked not to be contacted"),
+		"3": tools.Edit(json.RawMessage(`{"name": "Anna"}`)),
+	}
+
+	agent := NewAgent(llm, WithApprover(func(ctx context.Context, toolCall *tools.ToolCall) (*tools.ApprovalDecision, error) {
+		return decisions[toolCall.ID], nil
+	}))
+
+	_, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet everyone"))),
+		ai.WithTools(tools.NewToolbox(greetTool).RequireApproval(tools.ByList("greet"))...),
+	))
+	s.Require().NoError(err)
+
+	history := llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest).History
+	s.Require().Len(history, 7)
+	s.Require().JSONEq(`{"response":"Hello, John!"}`, string(history[4].(*ai.ToolResultMessage).Result))
+	s.Require().Equal("Tool call was rejected by the approver. Reason: Tom asked not to be contacted", history[5].(*ai.ToolResultMessage).Error)
+	s.Require().JSONEq(`{"response":"Hello, Anna!"}`, string(history[6].(*ai.ToolResultMessage).Result))
+}
+
 type MockLLM struct {
 	mock.Mock
 }
@@ -130,3 +530,298 @@ func (m *MockLLM) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai.LLMRe
 
 	return args.Get(0).(*ai.LLMResponse), args.Error(1)
 }
+
+type MockStreamingLLM struct {
+	MockLLM
+}
+
+func NewMockStreamingLLM() *MockStreamingLLM {
+	return &MockStreamingLLM{}
+}
+
+func (m *MockStreamingLLM) InvokeStream(ctx context.Context, request *ai.LLMRequest, handler ai.StreamHandler) (*ai.LLMResponse, error) {
+	args := m.Called(ctx, request, handler)
+
+	return args.Get(0).(*ai.LLMResponse), args.Error(1)
+}
+
+type Row struct {
+	Table  string `json:"table"`
+	Status string `json:"status"`
+}
+
+type Rows struct {
+	Rows []Row `json:"rows"`
+}
+
+var queryTool = tools.NewSimpleTool("query", "Query table statuses",
+	func(ctx context.Context, input *Req) (*Rows, error) {
+		return &Rows{Rows: []Row{
+			{Table: "orders", Status: "ok"},
+			{Table: "customers", Status: "ok"},
+			{Table: "payments", Status: "stale"},
+		}}, nil
+	},
+)
+
+// reducedEvents records results shrunk by result policies
+type reducedEvents struct {
+	*ai.NoopAgentEvents
+	original, reduced json.RawMessage
+}
+
+func (e *reducedEvents) OnToolResultReduced(ctx context.Context, toolCall *tools.ToolCall, original, reduced json.RawMessage) {
+	e.original, e.reduced = original, reduced
+}
+
+func (s *AgentSuite) TestAgentResultPolicies() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "query", json.RawMessage(`{"name": "statuses"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "John"}`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	events := &reducedEvents{NoopAgentEvents: ai.NewNoopAgentEvents()}
+	agent := NewAgent(llm,
+		WithEvents(events),
+		WithResultPolicy(TruncateResult(10)),
+		WithToolResultPolicy("query", TruncateArrays(1)),
+	)
+
+	_, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Check tables and greet John"))),
+		ai.WithTools(queryTool, greetTool),
+	))
+	s.Require().NoError(err)
+
+	history := llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest).History
+	s.Require().JSONEq(
+		`{"rows": [{"table": "orders", "status": "ok"}, "... 2 more items, 3 in total"]}`,
+		string(history[3].(*ai.ToolResultMessage).Result),
+	)
+	s.Require().Equal(
+		`"{\"response\n... [truncated, showing the first 10 of 27 bytes]"`,
+		string(history[4].(*ai.ToolResultMessage).Result),
+	)
+
+	// Events still see the full result
+	s.Require().JSONEq(`{"response":"Hello, John!"}`, string(events.original))
+	s.Require().Equal(history[4].(*ai.ToolResultMessage).Result, events.reduced)
+}
+
+func (s *AgentSuite) TestAgentSummarizeResult() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "query", json.RawMessage(`{"name": "statuses"}`))),
+		).SetUsage(ai.NewLLMUsage(100, 10, 110)), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("3 tables, payments is stale.")).SetUsage(ai.NewLLMUsage(50, 5, 55)), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Payments is stale.")).SetUsage(ai.NewLLMUsage(120, 10, 130)), nil).
+		Once()
+
+	agent := NewAgent(llm, WithResultPolicy(SummarizeResult(ai.NewLLMRequest(ai.WithModel(ai.Claude4Sonnet)), 50)))
+
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Which tables are stale?"))),
+		ai.WithTools(queryTool),
+	))
+	s.Require().NoError(err)
+
+	summarize := llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Equal(summarizeResultPrompt, summarize.System)
+	s.Require().Contains(summarize.History[0].(*ai.TextMessage).Content, `"table":"payments","status":"stale"`)
+
+	history := llm.Calls[2].Arguments.Get(1).(*ai.LLMRequest).History
+	s.Require().Equal(`"[summary of a 117 bytes result]\n3 tables, payments is stale."`, string(history[2].(*ai.ToolResultMessage).Result))
+
+	// Summary counts towards the tokens of the run but isn't a turn of its own
+	s.Require().Equal(int64(295), res.Usage.TotalTokens)
+	s.Require().Equal(int64(2), res.Usage.Turns)
+	s.Require().Len(res.Usage.Breakdown, 3)
+	s.Require().Equal(0, res.Usage.Breakdown[1].Turn)
+	s.Require().Equal(1, res.Usage.Breakdown[0].ToolCalls)
+	s.Require().Equal(0, res.Usage.Breakdown[1].ToolCalls)
+}
+
+func (s *AgentSuite) TestTruncateArrays() {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"short arrays", `{"rows": [1, 2]}`, `{"rows": [1, 2]}`},
+		{"top level", `[1, 2, 3, 4]`, `[1,2,"... 2 more items, 4 in total"]`},
+		{"nested", `{"b": [[1, 2, 3], [4], [5]], "a": "<x>"}`, `{"b":[[1,2,"... 1 more items, 3 in total"],[4],"... 1 more items, 3 in total"],"a":"<x>"}`},
+		{"skipped objects", `[{"a": [1, 2, 3]}, {"b": {"c": [1]}}, {"d": 1}]`, `[{"a":[1,2,"... 1 more items, 3 in total"]},{"b":{"c":[1]}},"... 1 more items, 3 in total"]`},
+		{"invalid", `[1, 2, 3`, `[1, 2, 3`},
+	} {
+		s.Run(tc.name, func() {
+			result, err := TruncateArrays(2)(context.Background(), nil, nil, json.RawMessage(tc.input))
+			s.Require().NoError(err)
+			s.Require().Equal(tc.expected, string(result))
+		})
+	}
+
+	// Multi-byte characters are never cut in half
+	result, err := TruncateResult(2)(context.Background(), nil, nil, json.RawMessage(`"é"`))
+	s.Require().NoError(err)
+	s.Require().Equal(`"\"\n... [truncated, showing the first 1 of 4 bytes]"`, string(result))
+}
+
+func (s *AgentSuite) TestTruncateLimits() {
+	for _, policy := range []ResultPolicy{TruncateResult(0), TruncateResult(-1), TruncateArrays(-1)} {
+		result, err := policy(context.Background(), nil, nil, json.RawMessage(`[1, 2]`))
+		s.Require().NoError(err)
+		s.Require().Equal(`[1, 2]`, string(result))
+	}
+
+	result, err := TruncateResult(1)(context.Background(), nil, nil, json.RawMessage(`[1, 2]`))
+	s.Require().NoError(err)
+	s.Require().Equal(`"[\n... [truncated, showing the first 1 of 6 bytes]"`, string(result))
+}
+
+func toolNames(toolbox tools.Toolbox) []string {
+	var names []string
+	for _, tool := range toolbox {
+		names = append(names, tool.Name())
+	}
+	return names
+}
+
+func (s *AgentSuite) TestAgentToolSearch() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", SearchToolsName, json.RawMessage(`{"query": "greet someone"}`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "John"}`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	notifyTool := tools.NewSimpleTool("notify", "Send a message to the team", func(ctx context.Context, input *Req) (*Res, error) {
+		return &Res{Response: "Sent."}, nil
+	})
+
+	agent := NewAgent(llm, WithToolSearch(tools.NewToolbox(notifyTool, greetTool), WithToolSearchLimit(1)))
+
+	res, err := agent.Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Greet John"))),
+		ai.WithTools(queryTool),
+	))
+	s.Require().NoError(err)
+
+	// Tools of the toolbox are only sent once found
+	first := llm.Calls[0].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Equal([]string{"query", SearchToolsName}, toolNames(first.Tools))
+
+	second := llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().JSONEq(
+		`{"tools": [{"name": "greet", "description": "Greet someone"}]}`,
+		string(second.History[2].(*ai.ToolResultMessage).Result),
+	)
+	s.Require().Equal([]string{"query", SearchToolsName, "greet"}, toolNames(second.Tools))
+
+	third := llm.Calls[2].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Equal(ai.NewToolResultMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "John"}`)), json.RawMessage(`{"response":"Hello, John!"}`)), third.History.Last())
+	s.Require().Equal(ai.NewAssistantMessage("Done."), res.Messages[0])
+}
+
+func (s *AgentSuite) TestAgentToolSearchWithoutToolUsage() {
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done.")), nil).
+		Once()
+
+	agent := NewAgent(llm, WithToolSearch(tools.NewToolbox(greetTool)))
+
+	// Request built by hand has no tool usage, the search tool is sent anyway
+	_, err := agent.Invoke(context.Background(), &ai.LLMRequest{History: ai.NewHistory(ai.NewUserMessage("Greet John"))})
+	s.Require().NoError(err)
+
+	request := llm.Calls[0].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Equal([]string{SearchToolsName}, toolNames(request.Tools))
+	s.Require().Equal(tools.AutoToolSelection(), request.ToolUsage)
+}
+
+func (s *AgentSuite) TestAgentToolAttachments() {
+	chartTool := tools.NewSimpleTool("chart", "Chart daily rows of a table", func(ctx context.Context, input *Req) (*Res, error) {
+		tools.Attach(ctx, &tools.Attachment{Type: tools.AttachmentImage, Data: []byte("png"), MIMEType: "image/png"})
+		return &Res{Response: "Chart attached."}, nil
+	})
+
+	llm := NewMockLLM()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "chart", json.RawMessage(`{"name": "orders"}`))),
+			ai.NewToolCallMessage(tools.NewToolCall("2", "greet", json.RawMessage(`{"name": "John"}`))),
+		), nil).
+		Once()
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Orders look fine.")), nil).
+		Once()
+
+	_, err := NewAgent(llm).Invoke(context.Background(), ai.NewLLMRequest(
+		ai.WithHistory(ai.NewHistory(ai.NewUserMessage("Chart orders"))),
+		ai.WithTools(chartTool, greetTool),
+	))
+	s.Require().NoError(err)
+
+	// Attachments follow results of the turn
+	history := llm.Calls[1].Arguments.Get(1).(*ai.LLMRequest).History
+	s.Require().Len(history, 6)
+	s.Require().Equal(ai.MessageKindToolResult, history[4].Kind())
+	s.Require().Equal(ai.NewUserMultiPartMessage(
+		ai.NewTextPart("Attachments of the chart result (call 1):"),
+		ai.NewImagePart([]byte("png"), "image/png"),
+	), history[5])
+}
+
+func (s *AgentSuite) TestToolCallRetryDropsAttachmentsOfFailedAttempts() {
+	attempts := 0
+	chartTool := tools.NewSimpleTool("chart", "Chart daily rows of a table", func(ctx context.Context, input *Req) (*Res, error) {
+		attempts++
+		tools.Attach(ctx, &tools.Attachment{Type: tools.AttachmentImage, Data: []byte(fmt.Sprintf("png %d", attempts)), MIMEType: "image/png"})
+		if attempts == 1 {
+			return nil, fmt.Errorf("warehouse unavailable")
+		}
+		return &Res{Response: "Chart attached."}, nil
+	})
+
+	info := &tools.ExecutionInfo{}
+	ctx := tools.WithExecutionInfo(context.Background(), info)
+	retriable := NewToolCallRetriable(NewMockLLM(), tools.NewToolCall("1", "chart", json.RawMessage(`{"name": "orders"}`)), chartTool, ai.NewNoopAgentEvents())
+
+	_, err := retriable.Retry(ctx, 0)
+	s.Require().Error(err)
+
+	_, err = retriable.Retry(ctx, 1)
+	s.Require().NoError(err)
+	s.Require().Equal([]*tools.Attachment{{Type: tools.AttachmentImage, Data: []byte("png 2"), MIMEType: "image/png"}}, info.Attachments)
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// Approver decides over calls of tools requiring approval, see tools.RequireApproval.
+// It returns tools.ErrApprovalPending when no decision is available yet, which
+// suspends the agent with ErrApprovalRequired.
+type Approver func(ctx context.Context, toolCall *tools.ToolCall) (*tools.ApprovalDecision, error)
+
+// WithApprover sets the approver of tool calls. Without one, every call of a tool
+// requiring approval suspends the agent.
+func WithApprover(approver Approver) AgentOpts {
+	return func(a *Agent) {
+		a.approver = approver
+	}
+}
+
+// WithResume resumes a run suspended with ErrApprovalRequired. Invoke executes the pending
+// tool calls, which the history must end with, before asking the model again. Tool calls
+// left unanswered in a history are never executed without it.
+func WithResume(pending []*tools.ToolCall) AgentOpts {
+	return func(a *Agent) {
+		a.resume = pending
+	}
+}
+
+// ErrApprovalRequired is returned when the agent suspends because tool calls wait for approval.
+// Response holds the entire conversation, ending with the pending tool calls, together with the
+// usage to that point. Invoking an agent created with WithResume(Pending) with that history
+// resumes the run, executing the pending calls once the approver has decisions for them.
+type ErrApprovalRequired struct {
+	Pending  []*tools.ToolCall
+	Response *ai.LLMResponse
+}
+
+func (e *ErrApprovalRequired) Error() string {
+	names := make([]string, len(e.Pending))
+	for i, toolCall := range e.Pending {
+		names[i] = toolCall.Name
+	}
+
+	return fmt.Sprintf("agent suspended: tool calls waiting for approval: %s", strings.Join(names, ", "))
+}
+
+// approvals collects decisions for calls of tools requiring approval,
+// calls without a decision are returned as pending
+func (a *Agent) approvals(ctx context.Context, request *ai.LLMRequest, toolCalls []*tools.ToolCall) (map[string]*tools.ApprovalDecision, []*tools.ToolCall, error) {
+	decisions := map[string]*tools.ApprovalDecision{}
+	var pending []*tools.ToolCall
+
+	for _, toolCall := range toolCalls {
+		tool, err := request.Tools.FindTool(toolCall.Name)
+		if err != nil || !tools.RequiresApproval(tool) {
+			continue
+		}
+
+		if a.approver == nil {
+			pending = append(pending, toolCall)
+			continue
+		}
+
+		decision, err := a.approver(ctx, toolCall)
+		if errors.Is(err, tools.ErrApprovalPending) {
+			pending = append(pending, toolCall)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to approve tool call %s: %w", toolCall.ID, err)
+		}
+
+		decisions[toolCall.ID] = decision
+	}
+
+	return decisions, pending, nil
+}
+
+// applyDecision returns the tool call to execute, or a result message if the call was rejected
+func applyDecision(toolCall *tools.ToolCall, decision *tools.ApprovalDecision) (*tools.ToolCall, ai.Message, error) {
+	if decision == nil {
+		return toolCall, nil, nil
+	}
+
+	switch decision.Action {
+	case tools.ApprovalApprove:
+		return toolCall, nil, nil
+
+	case tools.ApprovalReject:
+		message := "Tool call was rejected by the approver."
+		if decision.Reason != "" {
+			message += " Reason: " + decision.Reason
+		}
+
+		return nil, ai.NewToolResultErrorMessage(toolCall, message), nil
+
+	case tools.ApprovalEdit:
+		if len(decision.Args) == 0 {
+			return nil, nil, fmt.Errorf("edit of tool call %s has no arguments", toolCall.ID)
+		}
+
+		return tools.NewToolCall(toolCall.ID, toolCall.Name, decision.Args), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown approval action %q of tool call %s", decision.Action, toolCall.ID)
+}
+
+// resumedToolCalls returns tool calls the history ends with, checking they are the pending calls of the suspended run
+func resumedToolCalls(history ai.History, pending []*tools.ToolCall) ([]*tools.ToolCall, error) {
+	var toolCalls []*tools.ToolCall
+
+	for i := len(history) - 1; i >= 0; i-- {
+		message, ok := history[i].(*ai.ToolCallMessage)
+		if !ok {
+			break
+		}
+
+		toolCalls = append([]*tools.ToolCall{message.ToolCall}, toolCalls...)
+	}
+
+	ids := func(toolCalls []*tools.ToolCall) []string {
+		ids := make([]string, len(toolCalls))
+		for i, toolCall := range toolCalls {
+			ids[i] = toolCall.ID
+		}
+		return ids
+	}
+
+	if !slices.Equal(ids(toolCalls), ids(pending)) {
+		return nil, fmt.Errorf("can't resume, history doesn't end with the pending tool calls %s", strings.Join(ids(pending), ", "))
+	}
+
+	return toolCalls, nil
+}
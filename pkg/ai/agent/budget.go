@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+)
+
+// Budget names a limit the agent loop is bound by
+type Budget string
+
+const (
+	BudgetTurns     Budget = "turns"
+	BudgetTokens    Budget = "tokens"
+	BudgetToolCalls Budget = "tool_calls"
+	BudgetDuration  Budget = "duration"
+)
+
+// BudgetStrategy decides what happens once a budget is exceeded
+type BudgetStrategy string
+
+const (
+	// BudgetStrategyError stops the agent and returns ErrBudgetExceeded
+	BudgetStrategyError BudgetStrategy = "error"
+
+	// BudgetStrategyFinalAnswer makes one last call without tools to force the model to answer
+	BudgetStrategyFinalAnswer BudgetStrategy = "final_answer"
+)
+
+const finalAnswerPrompt = `You have run out of budget for further tool calls.
+Do not call any more tools. Answer now using only the information you already have.`
+
+// ErrBudgetExceeded is returned when the agent runs out of one of its budgets.
+// Response contains all messages produced by the agent before it stopped
+// together with the usage to that point.
+// For BudgetDuration the Limit is in milliseconds.
+type ErrBudgetExceeded struct {
+	Budget   Budget
+	Limit    int64
+	Response *ai.LLMResponse
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	if e.Budget == BudgetDuration {
+		return fmt.Sprintf("agent budget exceeded: %s limit of %s reached", e.Budget, time.Duration(e.Limit)*time.Millisecond)
+	}
+
+	return fmt.Sprintf("agent budget exceeded: %s limit of %d reached", e.Budget, e.Limit)
+}
+
+// WithMaxTurns limits the number of LLM calls the agent makes
+func WithMaxTurns(maxTurns int) AgentOpts {
+	return func(a *Agent) {
+		a.maxTurns = maxTurns
+	}
+}
+
+// WithMaxTokens limits the total number of tokens the agent spends
+func WithMaxTokens(maxTokens int64) AgentOpts {
+	return func(a *Agent) {
+		a.maxTokens = maxTokens
+	}
+}
+
+// WithMaxToolCalls limits the number of tool calls the agent executes
+func WithMaxToolCalls(maxToolCalls int) AgentOpts {
+	return func(a *Agent) {
+		a.maxToolCalls = maxToolCalls
+	}
+}
+
+// WithMaxDuration limits the wall-clock time of the agent loop. It is checked before
+// every LLM call, calls already in flight are not interrupted.
+func WithMaxDuration(maxDuration time.Duration) AgentOpts {
+	return func(a *Agent) {
+		a.maxDuration = maxDuration
+	}
+}
+
+// WithBudgetStrategy sets what the agent does when a budget is exceeded, defaults to BudgetStrategyError
+func WithBudgetStrategy(strategy BudgetStrategy) AgentOpts {
+	return func(a *Agent) {
+		a.budgetStrategy = strategy
+	}
+}
+
+// checkBudget is called before every LLM call, returns the exceeded budget if any
+func (a *Agent) checkBudget(turns int, usage *ai.LLMUsage, started time.Time) *ErrBudgetExceeded {
+	if a.maxTurns > 0 && turns >= a.maxTurns {
+		return &ErrBudgetExceeded{Budget: BudgetTurns, Limit: int64(a.maxTurns)}
+	}
+
+	if a.maxTokens > 0 && usage.TotalTokens >= a.maxTokens {
+		return &ErrBudgetExceeded{Budget: BudgetTokens, Limit: a.maxTokens}
+	}
+
+	if a.maxDuration > 0 && time.Since(started) >= a.maxDuration {
+		return &ErrBudgetExceeded{Budget: BudgetDuration, Limit: a.maxDuration.Milliseconds()}
+	}
+
+	return nil
+}
+
+// checkToolCallBudget is called before tool calls of a turn are executed
+func (a *Agent) checkToolCallBudget(executed, pending int) *ErrBudgetExceeded {
+	if a.maxToolCalls > 0 && executed+pending > a.maxToolCalls {
+		return &ErrBudgetExceeded{Budget: BudgetToolCalls, Limit: int64(a.maxToolCalls)}
+	}
+
+	return nil
+}
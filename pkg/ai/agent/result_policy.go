@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// ResultPolicy shrinks a tool result before it goes into the history, so a single large
+// result, e.g. a query returning thousands of rows, can't overflow the context window.
+// Results within the policy's limits are returned unchanged. The full result is still
+// passed to OnToolResult, OnToolResultReduced receives both once a policy changed it.
+type ResultPolicy func(ctx context.Context, llm ai.LLM, toolCall *tools.ToolCall, result json.RawMessage) (json.RawMessage, error)
+
+// WithResultPolicy applies the policies, in order, to results of every tool
+// without a policy of its own, see WithToolResultPolicy
+func WithResultPolicy(policies ...ResultPolicy) AgentOpts {
+	return func(a *Agent) {
+		a.resultPolicies = policies
+	}
+}
+
+// WithToolResultPolicy applies the policies, in order, to results of the named tool
+// instead of the ones set by WithResultPolicy
+func WithToolResultPolicy(tool string, policies ...ResultPolicy) AgentOpts {
+	return func(a *Agent) {
+		if a.toolResultPolicies == nil {
+			a.toolResultPolicies = map[string][]ResultPolicy{}
+		}
+
+		a.toolResultPolicies[tool] = policies
+	}
+}
+
+// reduceResult applies result policies of the tool to its result message
+func (a *Agent) reduceResult(ctx context.Context, toolCall *tools.ToolCall, message ai.Message) (ai.Message, error) {
+	policies, ok := a.toolResultPolicies[toolCall.Name]
+	if !ok {
+		policies = a.resultPolicies
+	}
+
+	// Results of tool searches are read back to find the tools they returned, so they are kept whole
+	resultMessage, ok := message.(*ai.ToolResultMessage)
+	if len(policies) == 0 || !ok || resultMessage.Error != "" || (a.toolSearch != nil && toolCall.Name == SearchToolsName) {
+		return message, nil
+	}
+
+	result := resultMessage.Result
+	for _, policy := range policies {
+		reduced, err := policy(ctx, a.llm, toolCall, result)
+		if err != nil {
+			return nil, err
+		}
+
+		result = reduced
+	}
+
+	if bytes.Equal(result, resultMessage.Result) {
+		return message, nil
+	}
+
+	a.events.OnToolResultReduced(ctx, toolCall, resultMessage.Result, result)
+	return ai.NewToolResultMessage(resultMessage.ToolCall, result), nil
+}
+
+// TruncateResult cuts results longer than maxBytes. The first maxBytes of the result are
+// passed on as a JSON string, followed by a marker telling the model how much was cut.
+// A maxBytes of 0 or less disables the policy.
+func TruncateResult(maxBytes int) ResultPolicy {
+	return func(ctx context.Context, llm ai.LLM, toolCall *tools.ToolCall, result json.RawMessage) (json.RawMessage, error) {
+		if maxBytes <= 0 || len(result) <= maxBytes {
+			return result, nil
+		}
+
+		// Don't cut a multi-byte character in half
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(result[cut]) {
+			cut--
+		}
+
+		truncated := fmt.Sprintf("%s\n... [truncated, showing the first %d of %d bytes]", result[:cut], cut, len(result))
+		return json.Marshal(truncated)
+	}
+}
+
+// TruncateArrays keeps the first maxItems items of every JSON array in the result, at any
+// depth. Cut arrays end with a string telling the model how many items were left out and
+// how many there were in total. Results which aren't valid JSON are returned unchanged.
+// A negative maxItems disables the policy.
+func TruncateArrays(maxItems int) ResultPolicy {
+	return func(ctx context.Context, llm ai.LLM, toolCall *tools.ToolCall, result json.RawMessage) (json.RawMessage, error) {
+		if maxItems < 0 {
+			return result, nil
+		}
+
+		truncated, ok := truncateArrays(result, maxItems)
+		if !ok {
+			return result, nil
+		}
+
+		return truncated, nil
+	}
+}
+
+// jsonFrame is an array or object being re-encoded by truncateArrays
+type jsonFrame struct {
+	array    bool
+	items    int
+	omitted  int
+	afterKey bool
+}
+
+// truncateArrays re-encodes the JSON token by token, so order of object keys is kept.
+// It reports false when nothing was cut or the data isn't valid JSON.
+func truncateArrays(data []byte, maxItems int) ([]byte, bool) {
+	// Decoder reports truncated input as a plain end of input, so check it upfront
+	if !json.Valid(data) {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var out bytes.Buffer
+	var stack []*jsonFrame
+	truncated := false
+
+	// skip is the depth of a value being left out of a cut array
+	skip := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+
+		delim, isDelim := token.(json.Delim)
+
+		if skip > 0 {
+			if delim == '[' || delim == '{' {
+				skip++
+			} else if delim == ']' || delim == '}' {
+				skip--
+			}
+			continue
+		}
+
+		if isDelim && (delim == ']' || delim == '}') {
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if frame.omitted > 0 {
+				if frame.items > 0 {
+					out.WriteByte(',')
+				}
+				marker, _ := json.Marshal(fmt.Sprintf("... %d more items, %d in total", frame.omitted, frame.items+frame.omitted))
+				out.Write(marker)
+			}
+
+			out.WriteByte(byte(delim))
+			continue
+		}
+
+		// Object keys are strings read while the object waits for a key
+		if len(stack) > 0 && !stack[len(stack)-1].array && !stack[len(stack)-1].afterKey {
+			frame := stack[len(stack)-1]
+			if frame.items > 0 {
+				out.WriteByte(',')
+			}
+			writeJSONValue(&out, token)
+			out.WriteByte(':')
+
+			frame.items++
+			frame.afterKey = true
+			continue
+		}
+
+		// Token starts a value of the enclosing array or object
+		if len(stack) > 0 {
+			frame := stack[len(stack)-1]
+			if frame.array {
+				if frame.items >= maxItems {
+					frame.omitted++
+					truncated = true
+					if isDelim {
+						skip = 1
+					}
+					continue
+				}
+
+				if frame.items > 0 {
+					out.WriteByte(',')
+				}
+				frame.items++
+			} else {
+				frame.afterKey = false
+			}
+		}
+
+		if isDelim {
+			out.WriteByte(byte(delim))
+			stack = append(stack, &jsonFrame{array: delim == '['})
+			continue
+		}
+
+		writeJSONValue(&out, token)
+	}
+
+	return out.Bytes(), truncated
+}
+
+// writeJSONValue encodes a scalar token without escaping HTML characters, the model reads them as they are
+func writeJSONValue(out *bytes.Buffer, value any) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	// Encode terminates every value with a newline
+	out.Truncate(out.Len() - 1)
+}
+
+const summarizeResultPrompt = `You summarize results of tool calls for an assistant with a limited context window.
+Keep every fact the assistant may need to continue its task: identifiers, names, numbers, errors and anomalies.
+Drop repetition and boilerplate. Answer with the summary only.`
+
+// SummarizeResult asks the LLM to summarize results longer than threshold bytes. The request
+// sets the model and optionally the system prompt, the tool call and its result are added
+// to its history. The summary is passed on as a JSON string, its usage counts towards the run.
+func SummarizeResult(request *ai.LLMRequest, threshold int) ResultPolicy {
+	return func(ctx context.Context, llm ai.LLM, toolCall *tools.ToolCall, result json.RawMessage) (json.RawMessage, error) {
+		if len(result) <= threshold {
+			return result, nil
+		}
+
+		summarize := request.Clone(
+			ai.WithAddedHistory(ai.NewHistory(ai.NewUserMessage(fmt.Sprintf(
+				"Tool: %s\nArguments: %s\n\nResult:\n%s", toolCall.Name, toolCall.Args, result,
+			)))),
+			ai.WithToolUsage(tools.NoToolSelection()),
+		)
+		if summarize.System == "" {
+			summarize.System = summarizeResultPrompt
+		}
+
+		response, err := llm.Invoke(ctx, summarize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize result of %s: %w", toolCall.Name, err)
+		}
+
+		if state, ok := runStateFrom(ctx); ok && response.Usage != nil {
+			state.addUsage(summarize.Model, response.Usage)
+		}
+
+		summary := response.LastMessageAsText()
+		if summary == nil {
+			return nil, fmt.Errorf("failed to summarize result of %s: last message is not a text message", toolCall.Name)
+		}
+
+		return json.Marshal(fmt.Sprintf("[summary of a %d bytes result]\n%s", len(result), summary.Content))
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// runState holds everything scoped to a single Invoke of the agent, so one agent
+// can be reused and called concurrently without mixing up usage
+type runState struct {
+	mu    sync.Mutex
+	usage *ai.LLMUsage
+
+	// index of the breakdown entry of the latest turn, tool calls count towards it
+	turnEntry int
+}
+
+type runStateKey struct{}
+
+func newRunState() *runState {
+	return &runState{usage: &ai.LLMUsage{}}
+}
+
+// withRunState returns a context carrying the run state, tool calls executed
+// within the run see it through runStateFrom
+func withRunState(ctx context.Context, state *runState) context.Context {
+	return context.WithValue(ctx, runStateKey{}, state)
+}
+
+func runStateFrom(ctx context.Context) (*runState, bool) {
+	state, ok := ctx.Value(runStateKey{}).(*runState)
+	return state, ok
+}
+
+// addTurn adds usage of one LLM call and records it in the breakdown under the given turn.
+// Calls the LLM didn't attribute to a model are attributed to the requested one.
+func (r *runState) addTurn(turn int, model ai.ModelId, usage *ai.LLMUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from := len(r.usage.Breakdown)
+	r.usage.Add(usage)
+
+	if len(r.usage.Breakdown) == from {
+		r.usage.Breakdown = append(r.usage.Breakdown, &ai.LLMUsageTurn{LLMUsageTokens: usage.LLMUsageTokens})
+	}
+
+	for _, entry := range r.usage.Breakdown[from:] {
+		entry.Turn = turn
+		if entry.Model == "" {
+			entry.Model = model
+		}
+	}
+
+	r.turnEntry = len(r.usage.Breakdown) - 1
+}
+
+// addUsage adds usage of an LLM call made on behalf of the latest turn, e.g. summarizing
+// a tool result. It's recorded in the breakdown but doesn't count as a turn.
+func (r *runState) addUsage(model ai.ModelId, usage *ai.LLMUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	turn := 0
+	if len(r.usage.Breakdown) > 0 {
+		turn = r.usage.Breakdown[r.turnEntry].Turn
+	}
+
+	r.usage.LLMUsageTokens.PromptTokens += usage.PromptTokens
+	r.usage.LLMUsageTokens.CachedPromptTokens += usage.CachedPromptTokens
+	r.usage.LLMUsageTokens.CompletionTokens += usage.CompletionTokens
+	r.usage.LLMUsageTokens.TotalTokens += usage.TotalTokens
+
+	r.usage.Breakdown = append(r.usage.Breakdown, &ai.LLMUsageTurn{Turn: turn, Model: model, LLMUsageTokens: usage.LLMUsageTokens})
+}
+
+// addToolCall records an executed tool call, counting it towards the latest turn
+func (r *runState) addToolCall(toolCall *tools.ToolCall, err error, cached bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cached {
+		r.usage.AddCachedToolCall(toolCall)
+	} else {
+		r.usage.AddToolCall(toolCall, err)
+	}
+
+	if len(r.usage.Breakdown) > 0 {
+		r.usage.Breakdown[r.turnEntry].ToolCalls++
+	}
+}
+
+// snapshot returns a copy of the usage to date, safe to hand out to callers
+func (r *runState) snapshot() *ai.LLMUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage := *r.usage
+	usage.ToolCalls = append([]*ai.LLMUsageToolCall(nil), r.usage.ToolCalls...)
+	usage.Breakdown = make([]*ai.LLMUsageTurn, len(r.usage.Breakdown))
+	for i, entry := range r.usage.Breakdown {
+		copied := *entry
+		usage.Breakdown[i] = &copied
+	}
+
+	return &usage
+}
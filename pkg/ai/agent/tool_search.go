@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// SearchToolsName is the name of the meta-tool added by WithToolSearch
+const SearchToolsName = "search_tools"
+
+// toolSearch keeps a large toolbox out of the request. The model finds the tools it
+// needs through the search_tools meta-tool, found tools are added to the request
+// for the remaining turns.
+type toolSearch struct {
+	toolbox tools.Toolbox
+	scorer  tools.ToolScorer
+	limit   int
+
+	tool tools.Tool
+}
+
+type ToolSearchOpts = func(*toolSearch)
+
+// WithToolScorer sets how tools are ranked against the query, defaults to tools.LexicalScorer
+func WithToolScorer(scorer tools.ToolScorer) ToolSearchOpts {
+	return func(s *toolSearch) {
+		s.scorer = scorer
+	}
+}
+
+// WithToolSearchLimit sets the maximum number of tools returned by one search, defaults to 5
+func WithToolSearchLimit(limit int) ToolSearchOpts {
+	return func(s *toolSearch) {
+		s.limit = limit
+	}
+}
+
+// WithToolSearch makes tools of the toolbox available through search only. Requests get the
+// search_tools meta-tool instead, tools it returns can be called from the next turn on.
+// Tools of the request itself are always available.
+func WithToolSearch(toolbox tools.Toolbox, opts ...ToolSearchOpts) AgentOpts {
+	return func(a *Agent) {
+		s := &toolSearch{toolbox: toolbox, scorer: tools.NewLexicalScorer(), limit: 5}
+		for _, opt := range opts {
+			opt(s)
+		}
+
+		s.tool = tools.NewSimpleTool(SearchToolsName, fmt.Sprintf(
+			"Search %d more tools by what they do. Only a few tools are available upfront, "+
+				"call this when none of them fits the task. Tools found become available to call right after.",
+			len(toolbox),
+		), s.search)
+
+		a.toolSearch = s
+	}
+}
+
+type searchToolsInput struct {
+	Query string `json:"query" jsonschema:"required" jsonschema_description:"What the tool should do, e.g. 'list columns of a table'"`
+	Limit int    `json:"limit,omitempty" jsonschema_description:"Maximum number of tools to return"`
+}
+
+type searchToolsOutput struct {
+	Tools []*searchedTool `json:"tools"`
+}
+
+type searchedTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (s *toolSearch) search(ctx context.Context, input *searchToolsInput) (*searchToolsOutput, error) {
+	limit := s.limit
+	if input.Limit > 0 && input.Limit < limit {
+		limit = input.Limit
+	}
+
+	matches, err := s.toolbox.Search(ctx, input.Query, limit, s.scorer)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &searchToolsOutput{Tools: []*searchedTool{}}
+	for _, match := range matches {
+		output.Tools = append(output.Tools, &searchedTool{Name: match.Tool.Name(), Description: match.Tool.Description()})
+	}
+
+	return output, nil
+}
+
+// withSearchedTools prepares the request for the next turn when tool search is enabled
+func (a *Agent) withSearchedTools(request *ai.LLMRequest) *ai.LLMRequest {
+	if a.toolSearch == nil {
+		return request
+	}
+
+	return a.toolSearch.prepare(request)
+}
+
+// prepare adds the search tool and every tool found by earlier searches to the request.
+// Found tools are read back from the history, so a resumed run gets them as well.
+func (s *toolSearch) prepare(request *ai.LLMRequest) *ai.LLMRequest {
+	available := append(tools.Toolbox(nil), request.Tools...)
+	add := func(tool tools.Tool) {
+		if _, err := available.FindTool(tool.Name()); err != nil {
+			available = available.AddTools(tool)
+		}
+	}
+
+	add(s.tool)
+	for _, name := range s.found(request.History) {
+		if tool, err := s.toolbox.FindTool(name); err == nil {
+			add(tool)
+		}
+	}
+
+	prepared := request.Clone()
+	prepared.Tools = available
+
+	// Adapters send tools only with a tool usage
+	if prepared.ToolUsage == nil {
+		prepared.ToolUsage = tools.AutoToolSelection()
+	}
+
+	return prepared
+}
+
+// found returns names of tools returned by searches in the history
+func (s *toolSearch) found(history ai.History) []string {
+	var names []string
+	for _, message := range history {
+		result, ok := message.(*ai.ToolResultMessage)
+		if !ok || result.ToolCall.Name != SearchToolsName || result.Error != "" {
+			continue
+		}
+
+		var output searchToolsOutput
+		if err := json.Unmarshal(result.Result, &output); err != nil {
+			continue
+		}
+
+		for _, tool := range output.Tools {
+			names = append(names, tool.Name)
+		}
+	}
+
+	return names
+}
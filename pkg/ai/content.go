@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// ContentPartType represents the type of a part of a multi-part message
+type ContentPartType string
+
+const (
+	ContentPartTypeText  ContentPartType = "text"
+	ContentPartTypeImage ContentPartType = "image"
+	ContentPartTypeFile  ContentPartType = "file"
+)
+
+// ContentPart is a single piece of a multi-part message. Images and files carry
+// either a URL or inline Data with its MIME type, Data is base64 encoded in JSON.
+type ContentPart struct {
+	Type     ContentPartType `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	Data     []byte          `json:"data,omitempty"`
+	MIMEType string          `json:"mime_type,omitempty"`
+	Filename string          `json:"filename,omitempty"`
+}
+
+func NewTextPart(text string) *ContentPart {
+	return &ContentPart{Type: ContentPartTypeText, Text: text}
+}
+
+func NewImageURLPart(url string) *ContentPart {
+	return &ContentPart{Type: ContentPartTypeImage, URL: url}
+}
+
+func NewImagePart(data []byte, mimeType string) *ContentPart {
+	return &ContentPart{Type: ContentPartTypeImage, Data: data, MIMEType: mimeType}
+}
+
+func NewFilePart(data []byte, mimeType, filename string) *ContentPart {
+	return &ContentPart{Type: ContentPartTypeFile, Data: data, MIMEType: mimeType, Filename: filename}
+}
+
+func NewFileURLPart(url, mimeType, filename string) *ContentPart {
+	return &ContentPart{Type: ContentPartTypeFile, URL: url, MIMEType: mimeType, Filename: filename}
+}
+
+// NewAttachmentPart turns an attachment of a tool result into an image or file part
+func NewAttachmentPart(attachment *tools.Attachment) *ContentPart {
+	switch {
+	case attachment.Type == tools.AttachmentImage && len(attachment.Data) > 0:
+		return NewImagePart(attachment.Data, attachment.MIMEType)
+	case attachment.Type == tools.AttachmentImage:
+		return NewImageURLPart(attachment.URL)
+	case len(attachment.Data) > 0:
+		return NewFilePart(attachment.Data, attachment.MIMEType, attachment.Filename)
+	default:
+		return NewFileURLPart(attachment.URL, attachment.MIMEType, attachment.Filename)
+	}
+}
+
+// DataURL returns the inline data as a data: URL, or the URL of the part if it has no data
+func (p *ContentPart) DataURL() string {
+	if len(p.Data) == 0 {
+		return p.URL
+	}
+
+	return fmt.Sprintf("data:%s;base64,%s", p.MIMEType, base64.StdEncoding.EncodeToString(p.Data))
+}
+
+// IsTextFile reports whether the part is a file with textual content (csv, json, markdown, ...),
+// which can be passed to models inline as text
+func (p *ContentPart) IsTextFile() bool {
+	if p.Type != ContentPartTypeFile || len(p.Data) == 0 {
+		return false
+	}
+
+	mimeType, _, _ := strings.Cut(p.MIMEType, ";")
+	switch mimeType {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml", "application/x-ndjson":
+		return true
+	}
+
+	return strings.HasPrefix(mimeType, "text/")
+}
+
+// TextFileContent renders a textual file as a fenced block titled by its filename
+func (p *ContentPart) TextFileContent() string {
+	return fmt.Sprintf("File: %s\n```\n%s\n```", p.Filename, p.Data)
+}
+
+// MultiPartMessage is a message mixing text with images and files
+type MultiPartMessage struct {
+	Parts []*ContentPart `json:"parts"`
+	Role_ MessageRole    `json:"role"`
+}
+
+func NewMultiPartMessage(role MessageRole, parts ...*ContentPart) *MultiPartMessage {
+	return &MultiPartMessage{
+		Parts: parts,
+		Role_: role,
+	}
+}
+
+func NewUserMultiPartMessage(parts ...*ContentPart) *MultiPartMessage {
+	return NewMultiPartMessage(MessageRoleUser, parts...)
+}
+
+func (m *MultiPartMessage) Kind() MessageKind {
+	return MessageKindMultiPart
+}
+
+func (m *MultiPartMessage) Role() MessageRole {
+	return m.Role_
+}
+
+// Text returns the text parts joined together, ignoring attachments
+func (m *MultiPartMessage) Text() string {
+	var texts []string
+	for _, part := range m.Parts {
+		if part.Type == ContentPartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n\n")
+}
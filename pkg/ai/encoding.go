@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Messages are encoded as JSON objects tagged with their kind, so a History
+// can be decoded back into concrete message types:
+//
+//	{"kind": "text", "content": "Hello", "role": "user"}
+
+// toolErrorTag tags encoded ToolErrorMessage, its Kind is shared with ToolResultMessage
+const toolErrorTag MessageKind = "tool_error"
+
+// marshalTagged encodes the message and puts its kind as the first field
+func marshalTagged(kind MessageKind, message any) ([]byte, error) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := fmt.Appendf(nil, `{"kind":%q`, kind)
+	if len(payload) > 2 {
+		tagged = append(tagged, ',')
+	}
+
+	return append(tagged, payload[1:]...), nil
+}
+
+func (m *TextMessage) MarshalJSON() ([]byte, error) {
+	type plain TextMessage
+	return marshalTagged(m.Kind(), (*plain)(m))
+}
+
+func (m *MultiPartMessage) MarshalJSON() ([]byte, error) {
+	type plain MultiPartMessage
+	return marshalTagged(m.Kind(), (*plain)(m))
+}
+
+func (m *ToolCallMessage) MarshalJSON() ([]byte, error) {
+	type plain ToolCallMessage
+	return marshalTagged(m.Kind(), (*plain)(m))
+}
+
+func (m *ToolResultMessage) MarshalJSON() ([]byte, error) {
+	type plain ToolResultMessage
+	return marshalTagged(m.Kind(), (*plain)(m))
+}
+
+// UnmarshalJSON decodes the message, keeping Result of a failed call nil rather than a JSON null
+func (m *ToolResultMessage) UnmarshalJSON(data []byte) error {
+	type plain ToolResultMessage
+	decoded := plain{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if string(decoded.Result) == "null" {
+		decoded.Result = nil
+	}
+
+	*m = ToolResultMessage(decoded)
+	return nil
+}
+
+func (m *ToolErrorMessage) MarshalJSON() ([]byte, error) {
+	type plain ToolErrorMessage
+	return marshalTagged(toolErrorTag, (*plain)(m))
+}
+
+// UnmarshalMessage decodes a single message using its kind field
+func UnmarshalMessage(data []byte) (Message, error) {
+	var tag struct {
+		Kind MessageKind `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return nil, err
+	}
+
+	var message Message
+	switch tag.Kind {
+	case MessageKindText:
+		message = &TextMessage{}
+	case MessageKindMultiPart:
+		message = &MultiPartMessage{}
+	case MessageKindToolCall:
+		message = &ToolCallMessage{}
+	case MessageKindToolResult:
+		message = &ToolResultMessage{}
+	case toolErrorTag:
+		message = &ToolErrorMessage{}
+	default:
+		return nil, fmt.Errorf("unknown message kind: %q", tag.Kind)
+	}
+
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s message: %w", tag.Kind, err)
+	}
+
+	return message, nil
+}
+
+func (h *History) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw == nil {
+		*h = nil
+		return nil
+	}
+
+	history := make(History, 0, len(raw))
+	for i, item := range raw {
+		message, err := UnmarshalMessage(item)
+		if err != nil {
+			return fmt.Errorf("message %d: %w", i, err)
+		}
+
+		history = append(history, message)
+	}
+
+	*h = history
+	return nil
+}
+
+// UnmarshalJSON decodes the response, keeping Usage non-nil as NewLLMResponse does
+func (r *LLMResponse) UnmarshalJSON(data []byte) error {
+	type plain LLMResponse
+	decoded := plain{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.Usage == nil {
+		decoded.Usage = NewLLMUsage(0, 0, 0)
+	}
+
+	*r = LLMResponse(decoded)
+	return nil
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden compares the payload with the golden file, rewriting it when run with -update
+func assertGolden(t *testing.T, name string, payload []byte) {
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		var indented bytes.Buffer
+		require.NoError(t, json.Indent(&indented, payload, "", "  "))
+		require.NoError(t, os.WriteFile(path, append(indented.Bytes(), '\n'), 0o644))
+	}
+
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, string(golden), string(payload))
+}
+
+func goldenHistory() History {
+	toolCall := tools.NewToolCall("call_1", "greet", json.RawMessage(`{"name":"John"}`))
+
+	return NewHistory(
+		NewSystemMessage("You greet people."),
+		NewUserMessage("Greet John"),
+		NewUserMultiPartMessage(
+			NewTextPart("Here is John"),
+			NewImageURLPart("https://example.com/john.png"),
+			NewImagePart([]byte("png"), "image/png"),
+			NewFilePart([]byte("name\nJohn"), "text/csv", "people.csv"),
+		),
+		NewAssistantMessage("Sure."),
+		NewToolCallMessage(toolCall),
+		NewToolResultMessage(toolCall, json.RawMessage(`{"response":"Hello, John!"}`)),
+		NewToolResultErrorMessage(toolCall, "greeting failed"),
+		NewToolErrorMessage(toolCall, "invalid arguments"),
+	)
+}
+
+func TestHistoryJSON(t *testing.T) {
+	history := goldenHistory()
+
+	payload, err := json.Marshal(history)
+	require.NoError(t, err)
+	assertGolden(t, "history.golden.json", payload)
+
+	var decoded History
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+	require.Equal(t, history, decoded)
+}
+
+func TestLLMResponseJSON(t *testing.T) {
+	toolCall := tools.NewToolCall("call_1", "greet", json.RawMessage(`{"name":"John"}`))
+
+	usage := NewLLMUsage(10, 5, 15).ForModel(Claude4Sonnet)
+	usage.AddToolCall(toolCall, nil)
+	usage.AddToolCall(toolCall, errors.New("greeting failed"))
+
+	response := NewLLMResponse(goldenHistory()...).SetUsage(usage)
+
+	payload, err := json.Marshal(response)
+	require.NoError(t, err)
+	assertGolden(t, "response.golden.json", payload)
+
+	decoded := &LLMResponse{}
+	require.NoError(t, json.Unmarshal(payload, decoded))
+	require.Equal(t, response, decoded)
+}
+
+func TestUnmarshalUnknownMessageKind(t *testing.T) {
+	var history History
+	err := json.Unmarshal([]byte(`[{"kind": "text", "content": "Hi", "role": "user"}, {"kind": "audio"}]`), &history)
+	require.ErrorContains(t, err, `message 1: unknown message kind: "audio"`)
+
+	decoded := &LLMResponse{}
+	require.NoError(t, json.Unmarshal([]byte(`{"messages": []}`), decoded))
+	require.NotNil(t, decoded.Usage)
+}
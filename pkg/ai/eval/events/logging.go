@@ -28,8 +28,8 @@ func (e *LoggingSuiteEvents) OnSuiteEnd(s *eval.Suite) {
 	// 	float64(s.Total.ExpectationsOk)/float64(s.Total.ExpectationsTotal)*100,
 	// )
 
-	fmt.Printf("  ~ usage input=%d, output=%d, total=%d\n",
-		s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens,
+	fmt.Printf("  ~ usage input=%d, output=%d, total=%d, cost=$%.4f\n",
+		s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens, s.Usage.Cost(),
 	)
 }
 
@@ -242,4 +242,27 @@ func (e *TableSuiteEvents) printUsage(usage *ai.LLMUsage) {
 	fmt.Println()
 	fmt.Println("=== Usage ===")
 	fmt.Printf("%s\n", usage)
+
+	// Cost per model, in order of first use
+	costs := map[ai.ModelId]float64{}
+	var models []ai.ModelId
+	for _, turn := range usage.Breakdown {
+		price, ok := ai.DefaultPricing().Price(turn.Model)
+		if !ok {
+			continue
+		}
+
+		if _, seen := costs[turn.Model]; !seen {
+			models = append(models, turn.Model)
+		}
+		costs[turn.Model] += price.Cost(turn.LLMUsageTokens)
+	}
+
+	for _, model := range models {
+		fmt.Printf("  - %s: $%.4f\n", model, costs[model])
+	}
+
+	if cached := usage.CachedToolCalls(); cached > 0 {
+		fmt.Printf("Cached tool calls: %d of %d\n", cached, len(usage.ToolCalls))
+	}
 }
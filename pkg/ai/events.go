@@ -7,6 +7,8 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"slices"
+	"sync"
 
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
 )
@@ -23,6 +25,17 @@ type AgentEvents interface {
 	OnToolCall(ctx context.Context, toolCall *tools.ToolCall)
 	OnToolError(ctx context.Context, toolCall *tools.ToolCall, attempt int, err error)
 	OnToolResult(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage)
+
+	// Called before OnToolResult when the result was served from a cache, see tools.CachedTool
+	OnToolCacheHit(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage)
+
+	// Called after OnToolResult when a result policy of the agent shrank the result,
+	// original is the full result returned by the tool, reduced is what the model sees
+	OnToolResultReduced(ctx context.Context, toolCall *tools.ToolCall, original, reduced json.RawMessage)
+
+	// Streaming deltas, only called when the agent streams from a StreamingLLM
+	OnTextDelta(ctx context.Context, request *LLMRequest, delta string)
+	OnToolCallDelta(ctx context.Context, request *LLMRequest, delta *ToolCallDelta)
 }
 
 type NoopAgentEvents struct{}
@@ -40,6 +53,13 @@ func (e *NoopAgentEvents) OnToolError(ctx context.Context, toolCall *tools.ToolC
 func (e *NoopAgentEvents) OnToolCall(ctx context.Context, toolCall *tools.ToolCall) {}
 func (e *NoopAgentEvents) OnToolResult(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
 }
+func (e *NoopAgentEvents) OnToolCacheHit(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
+}
+func (e *NoopAgentEvents) OnToolResultReduced(ctx context.Context, toolCall *tools.ToolCall, original, reduced json.RawMessage) {
+}
+func (e *NoopAgentEvents) OnTextDelta(ctx context.Context, request *LLMRequest, delta string) {}
+func (e *NoopAgentEvents) OnToolCallDelta(ctx context.Context, request *LLMRequest, delta *ToolCallDelta) {
+}
 
 type LogAgentEvents struct {
 	logger *slog.Logger
@@ -94,11 +114,43 @@ func (e *LogAgentEvents) OnToolResult(ctx context.Context, toolCall *tools.ToolC
 	e.logger.Info("tool call result", "tool", toolCall.Name, "result", string(result))
 }
 
+func (e *LogAgentEvents) OnToolCacheHit(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
+	e.logger.Info("tool call cache hit", "tool", toolCall.Name, "args", toolCall.Args)
+}
+
+func (e *LogAgentEvents) OnToolResultReduced(ctx context.Context, toolCall *tools.ToolCall, original, reduced json.RawMessage) {
+	e.logger.Info("tool call result reduced", "tool", toolCall.Name, "original_bytes", len(original), "reduced_bytes", len(reduced))
+}
+
+func (e *LogAgentEvents) OnTextDelta(ctx context.Context, request *LLMRequest, delta string) {
+	e.logger.Debug("text delta", "delta", delta)
+}
+
+func (e *LogAgentEvents) OnToolCallDelta(ctx context.Context, request *LLMRequest, delta *ToolCallDelta) {
+	e.logger.Debug("tool call delta", "index", delta.Index, "tool", delta.Name, "args", delta.Args)
+}
+
 func printMessage(message Message, textOnly bool) string {
 	switch t := message.(type) {
 	case *TextMessage:
 		return fmt.Sprintf("%s: %s", t.Role(), t.Content)
 
+	case *MultiPartMessage:
+		summary := fmt.Sprintf("%s: %s", t.Role(), t.Text())
+		for _, part := range t.Parts {
+			switch {
+			case part.Type == ContentPartTypeText:
+			case part.Filename != "":
+				summary += fmt.Sprintf(" [%s %s]", part.Type, part.Filename)
+			case part.URL != "":
+				summary += fmt.Sprintf(" [%s %s]", part.Type, part.URL)
+			default:
+				summary += fmt.Sprintf(" [%s %s]", part.Type, part.MIMEType)
+			}
+		}
+
+		return summary
+
 	case *ToolCallMessage:
 		if textOnly {
 			return ""
@@ -129,7 +181,11 @@ func printMessage(message Message, textOnly bool) string {
 	}
 }
 
+// MultiplexEvents fans out events to all registered listeners. It is safe for
+// concurrent use. Events of concurrent tool calls are delivered concurrently,
+// so listeners must be safe for concurrent use as well.
 type MultiplexEvents struct {
+	mu     sync.Mutex
 	events []AgentEvents
 }
 
@@ -138,41 +194,77 @@ func NewMultiplexEvents(events ...AgentEvents) *MultiplexEvents {
 }
 
 func (e *MultiplexEvents) Add(events ...AgentEvents) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.events = append(e.events, events...)
 }
 
+// listeners returns a snapshot of the listeners, so they are called without holding
+// the lock and a slow listener doesn't block events of concurrent tool calls
+func (e *MultiplexEvents) listeners() []AgentEvents {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return slices.Clone(e.events)
+}
+
 func (e *MultiplexEvents) OnRequest(ctx context.Context, request *LLMRequest) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnRequest(ctx, request)
 	}
 }
 
 func (e *MultiplexEvents) OnResponse(ctx context.Context, request *LLMRequest, response *LLMResponse, terminal bool) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnResponse(ctx, request, response, terminal)
 	}
 }
 
 func (e *MultiplexEvents) OnRequestError(ctx context.Context, request *LLMRequest, err error) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnRequestError(ctx, request, err)
 	}
 }
 
 func (e *MultiplexEvents) OnToolCall(ctx context.Context, toolCall *tools.ToolCall) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnToolCall(ctx, toolCall)
 	}
 }
 
 func (e *MultiplexEvents) OnToolError(ctx context.Context, toolCall *tools.ToolCall, attempt int, err error) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnToolError(ctx, toolCall, attempt, err)
 	}
 }
 
 func (e *MultiplexEvents) OnToolResult(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
-	for _, event := range e.events {
+	for _, event := range e.listeners() {
 		event.OnToolResult(ctx, toolCall, result)
 	}
 }
+
+func (e *MultiplexEvents) OnToolCacheHit(ctx context.Context, toolCall *tools.ToolCall, result json.RawMessage) {
+	for _, event := range e.listeners() {
+		event.OnToolCacheHit(ctx, toolCall, result)
+	}
+}
+
+func (e *MultiplexEvents) OnToolResultReduced(ctx context.Context, toolCall *tools.ToolCall, original, reduced json.RawMessage) {
+	for _, event := range e.listeners() {
+		event.OnToolResultReduced(ctx, toolCall, original, reduced)
+	}
+}
+
+func (e *MultiplexEvents) OnTextDelta(ctx context.Context, request *LLMRequest, delta string) {
+	for _, event := range e.listeners() {
+		event.OnTextDelta(ctx, request, delta)
+	}
+}
+
+func (e *MultiplexEvents) OnToolCallDelta(ctx context.Context, request *LLMRequest, delta *ToolCallDelta) {
+	for _, event := range e.listeners() {
+		event.OnToolCallDelta(ctx, request, delta)
+	}
+}
@@ -0,0 +1,58 @@
+package mcpclient
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ServerConfig describes how to reach an MCP server, either by starting a command
+// talking over stdio or by connecting to a streamable HTTP endpoint
+type ServerConfig struct {
+	// Name of the server, tools of the server are namespaced with it
+	Name string `json:"name" yaml:"name"`
+
+	Command string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// Transport creates the transport of every connection instead of Command or URL,
+	// e.g. in-memory transports in tests
+	Transport func() (mcp.Transport, error) `json:"-" yaml:"-"`
+}
+
+func (c *ServerConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("mcp server has no name")
+	}
+
+	if c.Transport == nil && (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("mcp server %s needs either a command or a url", c.Name)
+	}
+
+	return nil
+}
+
+// transport returns a new transport for a connection, a command is started anew for each
+func (c *ServerConfig) transport() (mcp.Transport, error) {
+	switch {
+	case c.Transport != nil:
+		return c.Transport()
+
+	case c.Command != "":
+		cmd := exec.Command(c.Command, c.Args...)
+		cmd.Env = os.Environ()
+		for key, value := range c.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+
+		return &mcp.CommandTransport{Command: cmd}, nil
+
+	default:
+		return &mcp.StreamableClientTransport{Endpoint: c.URL}, nil
+	}
+}
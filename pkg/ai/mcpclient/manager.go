@@ -0,0 +1,406 @@
+// Package mcpclient manages connections to MCP servers. It keeps their tools usable
+// across dropped connections and changes of the tools the servers offer.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// Manager connects to MCP servers and serves their tools as one toolbox. Tool names
+// are prefixed with the name of their server, e.g. warehouse__run_query. Dropped
+// connections are re-established with exponential backoff, tools are refreshed
+// whenever a server reports its tools changed.
+type Manager struct {
+	client  *mcp.Client
+	servers []*server
+
+	separator      string
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	keepAlive      time.Duration
+	onToolsChanged func(tools.Toolbox)
+	logger         *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu guards closed, goroutines are only added to wg while it's false
+	mu     sync.Mutex
+	closed bool
+}
+
+type ManagerOpts = func(*Manager)
+
+// WithNamespaceSeparator sets what separates server and tool names, defaults to "__"
+func WithNamespaceSeparator(separator string) ManagerOpts {
+	return func(m *Manager) {
+		m.separator = separator
+	}
+}
+
+// WithBackoff sets the delay before the first reconnection attempt, doubled after every
+// failed attempt up to max. Defaults to 500ms and 30s.
+func WithBackoff(initial, max time.Duration) ManagerOpts {
+	return func(m *Manager) {
+		m.initialBackoff = initial
+		m.maxBackoff = max
+	}
+}
+
+// WithKeepAlive pings servers at the interval, so dead connections are noticed without a call failing
+func WithKeepAlive(interval time.Duration) ManagerOpts {
+	return func(m *Manager) {
+		m.keepAlive = interval
+	}
+}
+
+// WithToolsChanged sets a callback receiving the new toolbox whenever tools of a server change
+func WithToolsChanged(callback func(tools.Toolbox)) ManagerOpts {
+	return func(m *Manager) {
+		m.onToolsChanged = callback
+	}
+}
+
+func WithLogger(logger *slog.Logger) ManagerOpts {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+func NewManager(configs []*ServerConfig, opts ...ManagerOpts) (*Manager, error) {
+	m := &Manager{
+		separator:      "__",
+		initialBackoff: 500 * time.Millisecond,
+		maxBackoff:     30 * time.Second,
+		logger:         slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	names := map[string]bool{}
+	for _, config := range configs {
+		if err := config.validate(); err != nil {
+			return nil, err
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate mcp server %s", config.Name)
+		}
+		names[config.Name] = true
+
+		m.servers = append(m.servers, &server{config: config})
+	}
+
+	m.client = mcp.NewClient(&mcp.Implementation{Name: "ai-data-sre", Version: "v1.0.0"}, &mcp.ClientOptions{
+		KeepAlive: m.keepAlive,
+		ToolListChangedHandler: func(ctx context.Context, request *mcp.ToolListChangedRequest) {
+			m.onToolListChanged(request.Session)
+		},
+	})
+
+	return m, nil
+}
+
+// Start connects to every server and keeps the connections up until Close.
+// It fails if any server can't be reached initially.
+func (m *Manager) Start(ctx context.Context) error {
+	m.ctx, m.cancel = context.WithCancel(context.WithoutCancel(ctx))
+
+	for _, s := range m.servers {
+		if err := m.connect(ctx, s); err != nil {
+			m.Close()
+			return err
+		}
+	}
+
+	for _, s := range m.servers {
+		m.wg.Add(1)
+		go m.watch(s)
+	}
+
+	m.toolsChanged()
+	return nil
+}
+
+// Close disconnects from every server, stopping started commands
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	var errs []error
+	for _, s := range m.servers {
+		if session := s.setSession(nil); session != nil {
+			errs = append(errs, session.Close())
+		}
+	}
+
+	m.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Toolbox returns tools of all servers. Tools keep working across reconnections, while a
+// server is disconnected its tools fail with a transient error, see tools.Retry.
+func (m *Manager) Toolbox() tools.Toolbox {
+	var toolbox tools.Toolbox
+	for _, s := range m.servers {
+		for _, tool := range s.listed() {
+			toolbox = append(toolbox, &managedTool{MCPTool: tool, name: s.config.Name + m.separator + tool.Name(), server: s})
+		}
+	}
+
+	return toolbox
+}
+
+// Session returns the current session of the named server, e.g. to read its resources
+func (m *Manager) Session(name string) (*mcp.ClientSession, error) {
+	for _, s := range m.servers {
+		if s.config.Name == name {
+			if session := s.current(); session != nil {
+				return session, nil
+			}
+
+			return nil, fmt.Errorf("mcp server %s is not connected", name)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown mcp server %s", name)
+}
+
+// connect opens a session to the server and lists its tools
+func (m *Manager) connect(ctx context.Context, s *server) error {
+	transport, err := s.config.transport()
+	if err != nil {
+		return fmt.Errorf("failed to create transport of mcp server %s: %w", s.config.Name, err)
+	}
+
+	session, err := m.client.Connect(ctx, transport, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mcp server %s: %w", s.config.Name, err)
+	}
+
+	listed, err := m.listTools(ctx, s, session)
+	if err != nil {
+		session.Close()
+		return err
+	}
+
+	// Manager may have been closed while connecting
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		session.Close()
+		return fmt.Errorf("failed to connect to mcp server %s: manager is closed", s.config.Name)
+	}
+
+	s.setSession(session)
+	s.setTools(session, listed)
+	return nil
+}
+
+// listTools lists tools of the server through the session
+func (m *Manager) listTools(ctx context.Context, s *server, session *mcp.ClientSession) ([]*tools.MCPTool, error) {
+	var listed []*tools.MCPTool
+	for tool, err := range session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tools of mcp server %s: %w", s.config.Name, err)
+		}
+
+		listed = append(listed, tools.NewFromMCPTool(session, tool))
+	}
+
+	return listed, nil
+}
+
+// watch waits for the session of the server to drop and reconnects
+func (m *Manager) watch(s *server) {
+	defer m.wg.Done()
+
+	for {
+		session := s.current()
+		if session == nil {
+			return
+		}
+
+		_ = session.Wait()
+		if m.ctx.Err() != nil {
+			return
+		}
+
+		m.logger.Warn("mcp server disconnected, reconnecting", "server", s.config.Name)
+		s.setSession(nil)
+
+		if !m.reconnect(s) {
+			return
+		}
+
+		m.logger.Info("mcp server reconnected", "server", s.config.Name)
+		m.toolsChanged()
+	}
+}
+
+// reconnect retries connecting until it succeeds or the manager is closed
+func (m *Manager) reconnect(s *server) bool {
+	backoff := m.initialBackoff
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := m.connect(m.ctx, s)
+		if err == nil {
+			return true
+		}
+
+		m.logger.Warn("failed to reconnect to mcp server", "server", s.config.Name, "error", err, "backoff", backoff)
+
+		backoff *= 2
+		if backoff > m.maxBackoff {
+			backoff = m.maxBackoff
+		}
+	}
+}
+
+// onToolListChanged refreshes tools of the server the notification came from. Notifications
+// are handled while the session reads messages, so tools are listed outside of the handler.
+// Tools listed through a session replaced in the meantime are dropped.
+func (m *Manager) onToolListChanged(session *mcp.ClientSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx == nil || m.closed {
+		return
+	}
+
+	for _, s := range m.servers {
+		if s.current() != session {
+			continue
+		}
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+
+			listed, err := m.listTools(m.ctx, s, session)
+			if err != nil {
+				m.logger.Warn("failed to refresh tools of mcp server", "server", s.config.Name, "error", err)
+				return
+			}
+
+			if s.setTools(session, listed) {
+				m.toolsChanged()
+			}
+		}()
+	}
+}
+
+func (m *Manager) toolsChanged() {
+	if m.onToolsChanged != nil {
+		m.onToolsChanged(m.Toolbox())
+	}
+}
+
+// server is the connection state of one configured server
+type server struct {
+	config *ServerConfig
+
+	mu      sync.RWMutex
+	session *mcp.ClientSession
+	tools   []*tools.MCPTool
+}
+
+func (s *server) current() *mcp.ClientSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.session
+}
+
+// setSession replaces the session, returning the previous one
+func (s *server) setSession(session *mcp.ClientSession) *mcp.ClientSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.session
+	s.session = session
+
+	return previous
+}
+
+func (s *server) listed() []*tools.MCPTool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.tools
+}
+
+// setTools replaces the tools if they were listed through the current session
+func (s *server) setTools(session *mcp.ClientSession, listed []*tools.MCPTool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session != session {
+		return false
+	}
+
+	s.tools = listed
+	return true
+}
+
+// managedTool calls the tool through the current session of its server
+type managedTool struct {
+	*tools.MCPTool
+
+	name   string
+	server *server
+}
+
+func (t *managedTool) Name() string {
+	return t.name
+}
+
+func (t *managedTool) Execute(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	session := t.server.current()
+	if session == nil {
+		return nil, tools.Transient(fmt.Errorf("mcp server %s is not connected", t.server.config.Name))
+	}
+
+	result, err := t.MCPTool.WithSession(session).Execute(ctx, args)
+	if isConnectionError(err) {
+		return nil, tools.Transient(err)
+	}
+
+	return result, err
+}
+
+// isConnectionError reports whether the call failed because the connection dropped
+func isConnectionError(err error) bool {
+	return errors.Is(err, mcp.ErrConnectionClosed) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed)
+}
+
+// Unwrap returns the tool as listed by the server
+func (t *managedTool) Unwrap() tools.Tool {
+	return t.MCPTool
+}
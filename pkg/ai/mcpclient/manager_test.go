@@ -0,0 +1,204 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+type ManagerSuite struct {
+	suite.Suite
+}
+
+func TestManagerSuite(t *testing.T) {
+	suite.Run(t, new(ManagerSuite))
+}
+
+type tableInput struct {
+	Table string `json:"table"`
+}
+
+type tableOutput struct {
+	Rows int `json:"rows"`
+}
+
+// testServer serves an MCP server over in-memory transports, one per connection
+type testServer struct {
+	server *mcp.Server
+
+	mu       sync.Mutex
+	sessions []*mcp.ServerSession
+}
+
+func newTestServer(name string, toolNames ...string) *testServer {
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "v1.0.0"}, nil)
+	for _, toolName := range toolNames {
+		mcp.AddTool(server, &mcp.Tool{Name: toolName, Description: "Count rows"}, func(ctx context.Context, request *mcp.CallToolRequest, in tableInput) (*mcp.CallToolResult, tableOutput, error) {
+			return nil, tableOutput{Rows: 42}, nil
+		})
+	}
+
+	return &testServer{server: server}
+}
+
+func (s *testServer) config(name string) *ServerConfig {
+	return &ServerConfig{
+		Name: name,
+		Transport: func() (mcp.Transport, error) {
+			serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+			session, err := s.server.Connect(context.Background(), serverTransport, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			s.mu.Lock()
+			s.sessions = append(s.sessions, session)
+			s.mu.Unlock()
+
+			return clientTransport, nil
+		},
+	}
+}
+
+// drop closes the latest connection from the server side
+func (s *testServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[len(s.sessions)-1].Close()
+}
+
+func (s *testServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sessions)
+}
+
+func toolNames(toolbox tools.Toolbox) []string {
+	var names []string
+	for _, tool := range toolbox {
+		names = append(names, tool.Name())
+	}
+	return names
+}
+
+func (s *ManagerSuite) start(configs []*ServerConfig, opts ...ManagerOpts) *Manager {
+	manager, err := NewManager(configs, append([]ManagerOpts{
+		WithBackoff(time.Millisecond, 10*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)...)
+	s.Require().NoError(err)
+	s.Require().NoError(manager.Start(context.Background()))
+	s.T().Cleanup(func() { manager.Close() })
+
+	return manager
+}
+
+func (s *ManagerSuite) TestNamespacedToolbox() {
+	warehouse := newTestServer("warehouse", "row_count", "run_query")
+	catalog := newTestServer("catalog", "row_count")
+
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse"), catalog.config("catalog")})
+
+	toolbox := manager.Toolbox()
+	s.Require().Equal([]string{"warehouse__row_count", "warehouse__run_query", "catalog__row_count"}, toolNames(toolbox))
+
+	result, err := toolbox[2].Execute(context.Background(), json.RawMessage(`{"table": "orders"}`))
+	s.Require().NoError(err)
+	s.Require().JSONEq(`{"rows": 42}`, string(result))
+
+	_, err = manager.Session("warehouse")
+	s.Require().NoError(err)
+
+	_, err = manager.Session("missing")
+	s.Require().EqualError(err, "unknown mcp server missing")
+}
+
+func (s *ManagerSuite) TestReconnect() {
+	warehouse := newTestServer("warehouse", "row_count")
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse")})
+
+	tool := manager.Toolbox()[0]
+	warehouse.drop()
+
+	// Tools obtained before the drop work again once reconnected
+	s.Require().Eventually(func() bool {
+		_, err := tool.Execute(context.Background(), json.RawMessage(`{"table": "orders"}`))
+		if err != nil {
+			s.Require().True(tools.IsTransient(err), err.Error())
+		}
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	s.Require().Equal(2, warehouse.connections())
+}
+
+func (s *ManagerSuite) TestToolListChanged() {
+	warehouse := newTestServer("warehouse", "row_count")
+
+	changes := make(chan tools.Toolbox, 10)
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse")}, WithToolsChanged(func(toolbox tools.Toolbox) {
+		changes <- toolbox
+	}))
+
+	s.Require().Equal([]string{"warehouse__row_count"}, toolNames(<-changes))
+
+	mcp.AddTool(warehouse.server, &mcp.Tool{Name: "run_query", Description: "Run a query"}, func(ctx context.Context, request *mcp.CallToolRequest, in tableInput) (*mcp.CallToolResult, tableOutput, error) {
+		return nil, tableOutput{}, nil
+	})
+
+	select {
+	case toolbox := <-changes:
+		s.Require().Equal([]string{"warehouse__row_count", "warehouse__run_query"}, toolNames(toolbox))
+	case <-time.After(time.Second):
+		s.Fail("tools were not refreshed")
+	}
+
+	s.Require().Len(manager.Toolbox(), 2)
+}
+
+func (s *ManagerSuite) TestToolListChangedAfterReconnectOrClose() {
+	warehouse := newTestServer("warehouse", "row_count")
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse")})
+
+	previous, err := manager.Session("warehouse")
+	s.Require().NoError(err)
+
+	warehouse.drop()
+	s.Require().Eventually(func() bool { return warehouse.connections() == 2 && manager.servers[0].current() != nil }, time.Second, time.Millisecond)
+
+	// Tools listed through the dropped session don't replace the current ones
+	s.Require().False(manager.servers[0].setTools(previous, nil))
+	s.Require().Len(manager.Toolbox(), 1)
+
+	// Notifications arriving while or after closing are ignored
+	current, err := manager.Session("warehouse")
+	s.Require().NoError(err)
+	s.Require().NoError(manager.Close())
+	manager.onToolListChanged(current)
+	manager.wg.Wait()
+}
+
+func (s *ManagerSuite) TestInvalidConfig() {
+	_, err := NewManager([]*ServerConfig{{Name: "warehouse"}})
+	s.Require().EqualError(err, "mcp server warehouse needs either a command or a url")
+
+	_, err = NewManager([]*ServerConfig{{Name: "warehouse", URL: "http://localhost:8080"}, {Name: "warehouse", Command: "warehouse-mcp"}})
+	s.Require().EqualError(err, "duplicate mcp server warehouse")
+
+	// Unreachable servers fail the start
+	manager, err := NewManager([]*ServerConfig{{Name: "missing", Command: "/nonexistent/mcp-server"}})
+	s.Require().NoError(err)
+	s.Require().ErrorContains(manager.Start(context.Background()), "failed to connect to mcp server missing")
+}
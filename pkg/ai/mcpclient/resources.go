@@ -0,0 +1,83 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+)
+
+// ReadResources reads the resources into a history of one user message per resource.
+// Contents are converted like embedded resources of tool results, see tools.MCPResourceAttachment.
+func ReadResources(ctx context.Context, session *mcp.ClientSession, uris ...string) (ai.History, error) {
+	history := ai.NewHistory()
+	for _, uri := range uris {
+		result, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resource %s: %w", uri, err)
+		}
+
+		parts := []*ai.ContentPart{ai.NewTextPart(fmt.Sprintf("Resource %s:", uri))}
+		for _, contents := range result.Contents {
+			if attachment := tools.MCPResourceAttachment(contents); attachment != nil {
+				parts = append(parts, ai.NewAttachmentPart(attachment))
+			} else {
+				parts = append(parts, ai.NewTextPart(fmt.Sprintf("[resource %s is empty]", contents.URI)))
+			}
+		}
+
+		history = history.Append(ai.NewUserMultiPartMessage(parts...))
+	}
+
+	return history, nil
+}
+
+// PreloadResources returns a callback of workflows.NewPreloadTask reading the resources, see ReadResources
+func PreloadResources(session *mcp.ClientSession, uris ...string) workflows.PreloadTaskCallback {
+	return func(ctx context.Context) (ai.History, error) {
+		return ReadResources(ctx, session, uris...)
+	}
+}
+
+// RenderPrompt gets the prompt rendered with the arguments and returns its messages. Content
+// is converted like content of tool results, see tools.ConvertMCPContent. Messages with images
+// or resources become multipart user messages, also for assistant messages, as models only take
+// images and files from users.
+func RenderPrompt(ctx context.Context, session *mcp.ClientSession, name string, args map[string]string) (ai.History, error) {
+	result, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+
+	history := ai.NewHistory()
+	for _, message := range result.Messages {
+		role := ai.MessageRoleUser
+		if message.Role == "assistant" {
+			role = ai.MessageRoleAssistant
+		}
+
+		text, attachments := tools.ConvertMCPContent([]mcp.Content{message.Content})
+		if len(attachments) == 0 {
+			if text != "" {
+				history = history.Append(&ai.TextMessage{Content: text, Role_: role})
+			}
+			continue
+		}
+
+		var parts []*ai.ContentPart
+		if text != "" {
+			parts = append(parts, ai.NewTextPart(text))
+		}
+		for _, attachment := range attachments {
+			parts = append(parts, ai.NewAttachmentPart(attachment))
+		}
+
+		history = history.Append(ai.NewUserMultiPartMessage(parts...))
+	}
+
+	return history, nil
+}
@@ -0,0 +1,92 @@
+package mcpclient
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+)
+
+func (s *ManagerSuite) TestPreloadResources() {
+	warehouse := newTestServer("warehouse")
+	warehouse.server.AddResource(&mcp.Resource{URI: "file:///schemas/orders.sql", Name: "orders"},
+		func(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: request.Params.URI, MIMEType: "application/sql", Text: "CREATE TABLE orders (id INT)"},
+			}}, nil
+		})
+	warehouse.server.AddResource(&mcp.Resource{URI: "file:///charts/orders.png", Name: "orders chart"},
+		func(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: request.Params.URI, MIMEType: "image/png", Blob: []byte("png")},
+			}}, nil
+		})
+
+	warehouse.server.AddResource(&mcp.Resource{URI: "file:///schemas/empty.sql", Name: "empty"},
+		func(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: request.Params.URI}}}, nil
+		})
+
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse")})
+	session, err := manager.Session("warehouse")
+	s.Require().NoError(err)
+
+	task := workflows.NewPreloadTask("schemas", PreloadResources(session, "file:///schemas/orders.sql", "file:///charts/orders.png", "file:///schemas/empty.sql"))
+	response, err := task.Invoke(context.Background(), nil, nil)
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewHistory(
+		ai.NewUserMultiPartMessage(
+			ai.NewTextPart("Resource file:///schemas/orders.sql:"),
+			ai.NewFilePart([]byte("CREATE TABLE orders (id INT)"), "text/plain", "orders.sql"),
+		),
+		ai.NewUserMultiPartMessage(
+			ai.NewTextPart("Resource file:///charts/orders.png:"),
+			ai.NewImagePart([]byte("png"), "image/png"),
+		),
+		ai.NewUserMultiPartMessage(
+			ai.NewTextPart("Resource file:///schemas/empty.sql:"),
+			ai.NewTextPart("[resource file:///schemas/empty.sql is empty]"),
+		),
+	), response.Messages)
+
+	_, err = ReadResources(context.Background(), session, "file:///missing")
+	s.Require().ErrorContains(err, "failed to read resource file:///missing")
+}
+
+func (s *ManagerSuite) TestRenderPrompt() {
+	warehouse := newTestServer("warehouse")
+	warehouse.server.AddPrompt(&mcp.Prompt{Name: "investigate", Arguments: []*mcp.PromptArgument{{Name: "table", Required: true}}},
+		func(ctx context.Context, request *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			table := request.Params.Arguments["table"]
+
+			return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{
+				{Role: "user", Content: &mcp.TextContent{Text: "Why did rows of " + table + " drop?"}},
+				{Role: "assistant", Content: &mcp.TextContent{Text: "Let me look at the schema of " + table + "."}},
+				{Role: "user", Content: &mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///schemas/" + table + ".sql", MIMEType: "application/sql", Text: "CREATE TABLE orders (id INT)"}}},
+				{Role: "assistant", Content: &mcp.ImageContent{Data: []byte("png"), MIMEType: "image/png"}},
+				{Role: "user", Content: &mcp.ResourceLink{URI: "file:///data/" + table + ".csv", Name: table + ".csv", MIMEType: "text/csv"}},
+				{Role: "user", Content: &mcp.AudioContent{Data: []byte("wav"), MIMEType: "audio/wav"}},
+			}}, nil
+		})
+
+	manager := s.start([]*ServerConfig{warehouse.config("warehouse")})
+	session, err := manager.Session("warehouse")
+	s.Require().NoError(err)
+
+	history, err := RenderPrompt(context.Background(), session, "investigate", map[string]string{"table": "orders"})
+	s.Require().NoError(err)
+	s.Require().Equal(ai.NewHistory(
+		ai.NewUserMessage("Why did rows of orders drop?"),
+		ai.NewAssistantMessage("Let me look at the schema of orders."),
+		ai.NewUserMultiPartMessage(ai.NewFilePart([]byte("CREATE TABLE orders (id INT)"), "text/plain", "orders.sql")),
+		// Models take images from users only
+		ai.NewUserMultiPartMessage(ai.NewImagePart([]byte("png"), "image/png")),
+		ai.NewUserMessage("Resource file:///data/orders.csv (orders.csv), text/csv"),
+		ai.NewUserMessage("[audio/wav audio omitted, audio is not supported]"),
+	), history)
+
+	_, err = RenderPrompt(context.Background(), session, "missing", nil)
+	s.Require().ErrorContains(err, "failed to get prompt missing")
+}
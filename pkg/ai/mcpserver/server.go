@@ -0,0 +1,218 @@
+// Package mcpserver publishes tools and tasks over the Model Context Protocol,
+// so other agents can use them the same way we use MCP servers through tools.MCPTool.
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+)
+
+// Server serves tools and tasks as MCP tools
+type Server struct {
+	server *mcp.Server
+
+	// llm invokes served tasks
+	llm ai.LLM
+
+	instructions string
+}
+
+type ServerOpts = func(*Server)
+
+// WithLLM sets the LLM served tasks are invoked with, required for AddTask
+func WithLLM(llm ai.LLM) ServerOpts {
+	return func(s *Server) {
+		s.llm = llm
+	}
+}
+
+// WithInstructions sets instructions sent to clients when they connect, e.g. how the tools fit together
+func WithInstructions(instructions string) ServerOpts {
+	return func(s *Server) {
+		s.instructions = instructions
+	}
+}
+
+func NewServer(name, version string, opts ...ServerOpts) *Server {
+	s := &Server{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.server = mcp.NewServer(&mcp.Implementation{Name: name, Version: version}, &mcp.ServerOptions{
+		Instructions: s.instructions,
+	})
+
+	return s
+}
+
+// MCPServer returns the underlying server, e.g. to add prompts or resources
+func (s *Server) MCPServer() *mcp.Server {
+	return s.server
+}
+
+// AddTools serves every tool of the toolbox under its own name
+func (s *Server) AddTools(toolbox tools.Toolbox) error {
+	for _, tool := range toolbox {
+		if err := s.AddTool(tool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddTool serves the tool. Its input and output schemas are published as they are,
+// the output schema only when it describes an object, as MCP requires. Attachments the
+// tool adds with tools.Attach are returned as images and embedded resources. Tools failing
+// to execute return an MCP tool error, so the calling model sees what went wrong.
+func (s *Server) AddTool(tool tools.Tool) error {
+	mcpTool, err := newMCPTool(tool.Name(), tool.Description(), tool.InputSchemaRaw(), tool.OutputSchemaRaw())
+	if err != nil {
+		return err
+	}
+
+	s.server.AddTool(mcpTool, func(ctx context.Context, request *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.Params.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage(`{}`)
+		}
+
+		info := &tools.ExecutionInfo{}
+		result, err := tool.Execute(tools.WithExecutionInfo(ctx, info), args)
+		if err != nil {
+			return toolError(err), nil
+		}
+
+		res := toolResult(result, mcpTool.OutputSchema != nil)
+		for _, attachment := range info.Attachments {
+			res.Content = append(res.Content, tools.MCPAttachmentContent(attachment))
+		}
+
+		return res, nil
+	})
+
+	return nil
+}
+
+// TaskInput is the input of tasks served by AddTask
+type TaskInput struct {
+	// Input is passed to the task as a user message
+	Input string `json:"input" jsonschema:"required" jsonschema_description:"Request for the task"`
+}
+
+// AddTask serves the task as a tool named after the task. The input becomes a user
+// message, the result is the last message of the task's response.
+func (s *Server) AddTask(task workflows.Task, description string) error {
+	if s.llm == nil {
+		return fmt.Errorf("failed to add task %s: server has no LLM, see WithLLM", task.Name())
+	}
+
+	mcpTool, err := newMCPTool(task.Name(), description, tools.DefaultSchemaGenerator.MustGenerate(new(TaskInput)), nil)
+	if err != nil {
+		return err
+	}
+
+	s.server.AddTool(mcpTool, func(ctx context.Context, request *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var input TaskInput
+		if err := json.Unmarshal(request.Params.Arguments, &input); err != nil {
+			return toolError(fmt.Errorf("failed to unmarshal input: %w", err)), nil
+		}
+
+		response, err := task.Invoke(ctx, s.llm, ai.NewHistory(ai.NewUserMessage(input.Input)))
+		if err != nil {
+			return toolError(err), nil
+		}
+
+		last := response.LastMessageAsText()
+		if last == nil {
+			return toolError(fmt.Errorf("task %s did not end with a text message", task.Name())), nil
+		}
+
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: last.Content}}}, nil
+	})
+
+	return nil
+}
+
+// Run serves clients over stdin and stdout until the client disconnects or the context is done
+func (s *Server) Run(ctx context.Context) error {
+	return s.server.Run(ctx, &mcp.StdioTransport{})
+}
+
+// Handler serves clients over streamable HTTP
+func (s *Server) Handler() http.Handler {
+	return mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return s.server
+	}, nil)
+}
+
+func newMCPTool(name, description string, inputSchema, outputSchema json.RawMessage) (*mcp.Tool, error) {
+	input, err := parseSchema(inputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input schema of %s: %w", name, err)
+	}
+
+	// Tools without arguments may have an empty schema, MCP requires an object
+	if input == nil {
+		input = &jsonschema.Schema{}
+	}
+	if input.Type == "" {
+		input.Type = "object"
+	}
+	if input.Type != "object" {
+		return nil, fmt.Errorf("invalid input schema of %s: type must be object, got %s", name, input.Type)
+	}
+
+	output, err := parseSchema(outputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema of %s: %w", name, err)
+	}
+
+	// Results which aren't objects can't be structured content, they are served as text only
+	if output != nil && output.Type != "object" {
+		output = nil
+	}
+
+	return &mcp.Tool{Name: name, Description: description, InputSchema: input, OutputSchema: output}, nil
+}
+
+// parseSchema returns nil for a missing schema
+func parseSchema(raw json.RawMessage) (*jsonschema.Schema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// toolResult returns the result as text, and as structured content for tools with an output schema
+func toolResult(result json.RawMessage, structured bool) *mcp.CallToolResult {
+	res := &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: string(result)}}}
+	if structured {
+		res.StructuredContent = json.RawMessage(result)
+	}
+
+	return res
+}
+
+func toolError(err error) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+		IsError: true,
+	}
+}
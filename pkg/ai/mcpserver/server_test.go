@@ -0,0 +1,177 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+)
+
+type ServerSuite struct {
+	suite.Suite
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
+
+type MockLLM struct {
+	mock.Mock
+}
+
+func (m *MockLLM) Invoke(ctx context.Context, request *ai.LLMRequest) (*ai.LLMResponse, error) {
+	args := m.Called(ctx, request)
+
+	return args.Get(0).(*ai.LLMResponse), args.Error(1)
+}
+
+type tableInput struct {
+	Table string `json:"table" jsonschema:"required"`
+}
+
+type tableOutput struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+var rowCountTool = tools.NewSimpleTool("row_count", "Count rows of a table", func(ctx context.Context, in *tableInput) (*tableOutput, error) {
+	if in.Table == "missing" {
+		return nil, errors.New("table missing does not exist")
+	}
+
+	return &tableOutput{Table: in.Table, Rows: 42}, nil
+})
+
+// connect serves the server over an in-memory transport and returns a connected client session
+func (s *ServerSuite) connect(server *Server) *mcp.ClientSession {
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	serverSession, err := server.MCPServer().Connect(ctx, serverTransport, nil)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v1.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { session.Close() })
+
+	return session
+}
+
+func (s *ServerSuite) TestTools() {
+	server := NewServer("warehouse", "v1.0.0")
+	s.Require().NoError(server.AddTools(tools.NewToolbox(rowCountTool)))
+
+	ctx := context.Background()
+	session := s.connect(server)
+
+	// Served tools are usable as any other MCP tools
+	toolbox := tools.GetMCPTools(ctx, session)
+	s.Require().Len(toolbox, 1)
+	s.Require().Equal("row_count", toolbox[0].Name())
+	s.Require().Equal("Count rows of a table", toolbox[0].Description())
+	s.Require().JSONEq(`{"table": {"type": "string"}}`, schemaProperties(s, toolbox[0].InputSchemaRaw()))
+	s.Require().JSONEq(`{"rows": {"type": "integer"}, "table": {"type": "string"}}`, schemaProperties(s, toolbox[0].OutputSchemaRaw()))
+
+	result, err := toolbox[0].Execute(ctx, json.RawMessage(`{"table": "orders"}`))
+	s.Require().NoError(err)
+	s.Require().JSONEq(`{"table": "orders", "rows": 42}`, string(result))
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "row_count", Arguments: map[string]any{"table": "orders"}})
+	s.Require().NoError(err)
+	s.Require().False(res.IsError)
+	s.Require().Equal(map[string]any{"table": "orders", "rows": float64(42)}, res.StructuredContent)
+}
+
+func (s *ServerSuite) TestToolError() {
+	server := NewServer("warehouse", "v1.0.0")
+	s.Require().NoError(server.AddTool(rowCountTool))
+
+	res, err := s.connect(server).CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "row_count",
+		Arguments: map[string]any{"table": "missing"},
+	})
+	s.Require().NoError(err)
+	s.Require().True(res.IsError)
+	s.Require().Equal("tool execution failed: table missing does not exist", res.Content[0].(*mcp.TextContent).Text)
+}
+
+func (s *ServerSuite) TestToolAttachments() {
+	chartTool := tools.NewSimpleTool("chart", "Chart rows of a table", func(ctx context.Context, in *tableInput) (*tableOutput, error) {
+		tools.Attach(ctx,
+			&tools.Attachment{Type: tools.AttachmentImage, Data: []byte("png"), MIMEType: "image/png"},
+			&tools.Attachment{Type: tools.AttachmentFile, Data: []byte("day,rows\n1,42"), MIMEType: "text/csv", Filename: "rows.csv"},
+			&tools.Attachment{Type: tools.AttachmentFile, Data: []byte("pdf"), MIMEType: "application/pdf", Filename: "report.pdf"},
+		)
+		return &tableOutput{Table: in.Table, Rows: 42}, nil
+	})
+
+	server := NewServer("warehouse", "v1.0.0")
+	s.Require().NoError(server.AddTool(chartTool))
+
+	ctx := context.Background()
+	session := s.connect(server)
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "chart", Arguments: map[string]any{"table": "orders"}})
+	s.Require().NoError(err)
+	s.Require().Len(res.Content, 4)
+	s.Require().Equal(&mcp.ImageContent{Data: []byte("png"), MIMEType: "image/png"}, res.Content[1])
+	s.Require().Equal(&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "attachment:///rows.csv", MIMEType: "text/csv", Text: "day,rows\n1,42"}}, res.Content[2])
+	s.Require().Equal(&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "attachment:///report.pdf", MIMEType: "application/pdf", Blob: []byte("pdf")}}, res.Content[3])
+
+	// Clients using the served tool get the attachments back
+	info := &tools.ExecutionInfo{}
+	_, err = tools.GetMCPTools(ctx, session)[0].Execute(tools.WithExecutionInfo(ctx, info), json.RawMessage(`{"table": "orders"}`))
+	s.Require().NoError(err)
+	s.Require().Equal([]*tools.Attachment{
+		{Type: tools.AttachmentImage, Data: []byte("png"), MIMEType: "image/png"},
+		{Type: tools.AttachmentFile, Data: []byte("day,rows\n1,42"), MIMEType: "text/csv", Filename: "rows.csv"},
+		{Type: tools.AttachmentFile, Data: []byte("pdf"), MIMEType: "application/pdf", Filename: "report.pdf"},
+	}, info.Attachments)
+}
+
+func (s *ServerSuite) TestTask() {
+	llm := &MockLLM{}
+	llm.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Orders has 42 rows.")), nil).
+		Once()
+
+	task := workflows.NewTask("summarize", ai.NewLLMRequest(ai.WithSystem("Summarize table stats.")))
+
+	server := NewServer("warehouse", "v1.0.0")
+	s.Require().EqualError(server.AddTask(task, "Summarize stats of tables"), "failed to add task summarize: server has no LLM, see WithLLM")
+
+	server = NewServer("warehouse", "v1.0.0", WithLLM(llm))
+	s.Require().NoError(server.AddTask(task, "Summarize stats of tables"))
+
+	res, err := s.connect(server).CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "summarize",
+		Arguments: map[string]any{"input": "How big is orders?"},
+	})
+	s.Require().NoError(err)
+	s.Require().False(res.IsError)
+	s.Require().Equal("Orders has 42 rows.", res.Content[0].(*mcp.TextContent).Text)
+
+	request := llm.Calls[0].Arguments.Get(1).(*ai.LLMRequest)
+	s.Require().Equal(ai.NewHistory(ai.NewUserMessage("How big is orders?")), request.History)
+}
+
+// schemaProperties returns properties of a JSON schema
+func schemaProperties(s *ServerSuite, schema json.RawMessage) string {
+	var parsed struct {
+		Properties json.RawMessage `json:"properties"`
+	}
+	s.Require().NoError(json.Unmarshal(schema, &parsed))
+
+	return string(parsed.Properties)
+}
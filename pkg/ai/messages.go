@@ -19,6 +19,7 @@ const (
 	MessageKindText       MessageKind = "text"
 	MessageKindToolCall   MessageKind = "tool_call"
 	MessageKindToolResult MessageKind = "tool_result"
+	MessageKindMultiPart  MessageKind = "multipart"
 )
 
 // MessageRole represents the role of the message sender
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+// CachedInput falls back to Input when not set.
+type ModelPrice struct {
+	Input       float64 `json:"input" yaml:"input"`
+	Output      float64 `json:"output" yaml:"output"`
+	CachedInput float64 `json:"cached_input,omitempty" yaml:"cached_input,omitempty"`
+}
+
+// Cost returns the price of the given token counts
+func (p *ModelPrice) Cost(tokens LLMUsageTokens) float64 {
+	cachedInput := p.CachedInput
+	if cachedInput == 0 {
+		cachedInput = p.Input
+	}
+
+	uncached := tokens.PromptTokens - tokens.CachedPromptTokens
+
+	return (float64(uncached)*p.Input +
+		float64(tokens.CachedPromptTokens)*cachedInput +
+		float64(tokens.CompletionTokens)*p.Output) / 1_000_000
+}
+
+// Pricing is a price table keyed by model id, safe for concurrent use
+type Pricing struct {
+	mu     sync.RWMutex
+	prices map[ModelId]*ModelPrice
+}
+
+func NewPricing(prices map[ModelId]*ModelPrice) *Pricing {
+	p := &Pricing{prices: make(map[ModelId]*ModelPrice, len(prices))}
+	for model, price := range prices {
+		p.prices[model] = price
+	}
+
+	return p
+}
+
+// Set adds or replaces the price of a model
+func (p *Pricing) Set(model ModelId, price *ModelPrice) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prices[model] = price
+}
+
+// Price returns the price of a model, false if the model is not priced
+func (p *Pricing) Price(model ModelId) (*ModelPrice, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	price, ok := p.prices[model]
+	return price, ok
+}
+
+// Merge copies all prices of the other table into this one, overriding existing ones
+func (p *Pricing) Merge(other *Pricing) {
+	// Copy first, holding both locks at once deadlocks merges into itself or in opposite directions
+	other.mu.RLock()
+	prices := make(map[ModelId]*ModelPrice, len(other.prices))
+	for model, price := range other.prices {
+		prices[model] = price
+	}
+	other.mu.RUnlock()
+
+	for model, price := range prices {
+		p.Set(model, price)
+	}
+}
+
+// LoadPricing reads a price table from a YAML or JSON file, the format is picked by extension.
+// The file maps model ids to prices:
+//
+//	claude-4-sonnet:
+//	  input: 3
+//	  output: 15
+//	  cached_input: 0.3
+func LoadPricing(path string) (*Pricing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing: %w", err)
+	}
+
+	prices := map[ModelId]*ModelPrice{}
+
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		err = json.Unmarshal(data, &prices)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &prices)
+	default:
+		return nil, fmt.Errorf("unsupported pricing format: %s", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pricing %s: %w", path, err)
+	}
+
+	return NewPricing(prices), nil
+}
+
+var (
+	defaultPricingMu sync.RWMutex
+	defaultPricing   = NewPricing(map[ModelId]*ModelPrice{
+		Claude3Sonnet: {Input: 3, Output: 15, CachedInput: 0.3},
+		Claude4Sonnet: {Input: 3, Output: 15, CachedInput: 0.3},
+		Claude3Haiku:  {Input: 0.8, Output: 4, CachedInput: 0.08},
+		Gemini25Flash: {Input: 0.3, Output: 2.5, CachedInput: 0.075},
+		Gemini25Pro:   {Input: 1.25, Output: 10, CachedInput: 0.31},
+	})
+)
+
+// DefaultPricing returns the price table used by LLMUsage.Cost
+func DefaultPricing() *Pricing {
+	defaultPricingMu.RLock()
+	defer defaultPricingMu.RUnlock()
+
+	return defaultPricing
+}
+
+// SetDefaultPricing replaces the price table used by LLMUsage.Cost
+func SetDefaultPricing(pricing *Pricing) {
+	defaultPricingMu.Lock()
+	defer defaultPricingMu.Unlock()
+
+	defaultPricing = pricing
+}
@@ -17,6 +17,10 @@ type Block struct {
 	content string
 	title   string
 	level   int
+
+	// attachment is set for image and file blocks, which only render
+	// in BuildMultiPartUserMessage
+	attachment *ai.ContentPart
 }
 
 // BlockOption is a function that configures a block
@@ -166,41 +170,95 @@ func (pb *PromptBuilder) AddProtosBlock(msgs ...proto.Message) *PromptBuilder {
 	return pb.AddBlock(content)
 }
 
-// Build renders all blocks to markdown
+// AddAttachment adds an image or file block, placed between the surrounding markdown blocks
+func (pb *PromptBuilder) AddAttachment(part *ai.ContentPart, opts ...BlockOption) *PromptBuilder {
+	pb.AddBlock("", opts...)
+	pb.blocks[len(pb.blocks)-1].attachment = part
+	return pb
+}
+
+// AddImage adds an inline image block
+func (pb *PromptBuilder) AddImage(data []byte, mimeType string, opts ...BlockOption) *PromptBuilder {
+	return pb.AddAttachment(ai.NewImagePart(data, mimeType), opts...)
+}
+
+// AddImageURL adds an image block referencing the image by URL
+func (pb *PromptBuilder) AddImageURL(url string, opts ...BlockOption) *PromptBuilder {
+	return pb.AddAttachment(ai.NewImageURLPart(url), opts...)
+}
+
+// AddFile adds an inline file block
+func (pb *PromptBuilder) AddFile(data []byte, mimeType, filename string, opts ...BlockOption) *PromptBuilder {
+	return pb.AddAttachment(ai.NewFilePart(data, mimeType, filename), opts...)
+}
+
+// Build renders all blocks to markdown. Attachments are left out, only their titles are rendered.
 func (pb *PromptBuilder) Build() string {
 	var result []string
 
 	for _, block := range pb.blocks {
-		var blockContent []string
-
-		// Add title if present
-		if block.title != "" {
-			hashes := strings.Repeat("#", block.level)
-			blockContent = append(blockContent, fmt.Sprintf("%s %s", hashes, block.title))
-			// Add a blank line after the heading if there is content
-			if block.content != "" {
-				blockContent = append(blockContent, "")
-			}
+		if content := block.render(); content != "" {
+			result = append(result, content)
 		}
+	}
+
+	return strings.Join(result, "\n\n")
+}
+
+// render renders the markdown of a single block
+func (block *Block) render() string {
+	var blockContent []string
 
-		// Add content if present
+	// Add title if present
+	if block.title != "" {
+		hashes := strings.Repeat("#", block.level)
+		blockContent = append(blockContent, fmt.Sprintf("%s %s", hashes, block.title))
+		// Add a blank line after the heading if there is content
 		if block.content != "" {
-			blockContent = append(blockContent, block.content)
+			blockContent = append(blockContent, "")
 		}
+	}
 
-		// Join block content
-		if len(blockContent) > 0 {
-			result = append(result, strings.Join(blockContent, "\n"))
-		}
+	// Add content if present
+	if block.content != "" {
+		blockContent = append(blockContent, block.content)
 	}
 
-	return strings.Join(result, "\n\n")
+	return strings.Join(blockContent, "\n")
 }
 
 func (pb *PromptBuilder) BuildUserMessage() *ai.TextMessage {
 	return ai.NewUserMessage(pb.Build())
 }
 
+// BuildMultiPartUserMessage renders blocks to a message mixing markdown text parts with
+// attachments, keeping the order in which they were added
+func (pb *PromptBuilder) BuildMultiPartUserMessage() *ai.MultiPartMessage {
+	var parts []*ai.ContentPart
+	var text []string
+
+	flush := func() {
+		if len(text) > 0 {
+			parts = append(parts, ai.NewTextPart(strings.Join(text, "\n\n")))
+			text = nil
+		}
+	}
+
+	for _, block := range pb.blocks {
+		if content := block.render(); content != "" {
+			text = append(text, content)
+		}
+
+		if block.attachment != nil {
+			flush()
+			parts = append(parts, block.attachment)
+		}
+	}
+	flush()
+
+	return ai.NewUserMultiPartMessage(parts...)
+}
+
 // String implements the Stringer interface
 func (pb *PromptBuilder) String() string {
 	return pb.Build()
@@ -1,10 +1,17 @@
 package ai
 
-import "github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+import (
+	"maps"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
 
 type LLMResponse struct {
 	Messages History   `json:"messages"`
 	Usage    *LLMUsage `json:"usage"`
+
+	// Metadata holds decisions made while producing the response, e.g. the route picked by a router
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 func NewLLMResponse(messages ...Message) *LLMResponse {
@@ -18,6 +25,10 @@ func (r *LLMResponse) AddMessage(message Message) {
 	r.Messages = r.Messages.Append(message)
 }
 
+func (r *LLMResponse) AddMessages(messages ...Message) {
+	r.Messages = r.Messages.Append(messages...)
+}
+
 func (r *LLMResponse) AddToolCall(functionCall *tools.ToolCall) {
 	r.Messages = r.Messages.Append(NewToolCallMessage(functionCall))
 }
@@ -29,6 +40,7 @@ func (r *LLMResponse) Clone() *LLMResponse {
 	return &LLMResponse{
 		Messages: messages,
 		Usage:    r.Usage,
+		Metadata: maps.Clone(r.Metadata),
 	}
 }
 
@@ -64,3 +76,23 @@ func (r *LLMResponse) SetUsage(usage *LLMUsage) *LLMResponse {
 func (r *LLMResponse) AddUsage(usage *LLMUsage) {
 	r.Usage.Add(usage)
 }
+
+func (r *LLMResponse) SetMetadata(key string, value any) *LLMResponse {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]any)
+	}
+
+	r.Metadata[key] = value
+	return r
+}
+
+// MergeMetadata copies metadata of other response, keeping existing keys
+func (r *LLMResponse) MergeMetadata(other *LLMResponse) *LLMResponse {
+	for key, value := range other.Metadata {
+		if _, ok := r.Metadata[key]; !ok {
+			r.SetMetadata(key, value)
+		}
+	}
+
+	return r
+}
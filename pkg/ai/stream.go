@@ -0,0 +1,52 @@
+package ai
+
+import "context"
+
+// StreamingLLM is an LLM that can report partial results while the completion
+// is being generated. The final response is the same as the one returned by Invoke.
+type StreamingLLM interface {
+	LLM
+	InvokeStream(ctx context.Context, request *LLMRequest, handler StreamHandler) (*LLMResponse, error)
+}
+
+// StreamHandler receives chunks in the order they arrive from the model
+type StreamHandler func(chunk *LLMChunk)
+
+// LLMChunkKind represents the type of streamed chunk
+type LLMChunkKind string
+
+const (
+	LLMChunkKindText     LLMChunkKind = "text"
+	LLMChunkKindToolCall LLMChunkKind = "tool_call"
+	LLMChunkKindUsage    LLMChunkKind = "usage"
+)
+
+// LLMChunk is a single streamed piece of a completion. Exactly one of
+// Text, ToolCall or Usage is set depending on the Kind.
+type LLMChunk struct {
+	Kind     LLMChunkKind   `json:"kind"`
+	Text     string         `json:"text,omitempty"`
+	ToolCall *ToolCallDelta `json:"tool_call,omitempty"`
+	Usage    *LLMUsage      `json:"usage,omitempty"`
+}
+
+// ToolCallDelta is a partial tool call. ID and Name are only set on the first
+// delta of a tool call, Args carries the next fragment of the JSON arguments.
+type ToolCallDelta struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Args  string `json:"args,omitempty"`
+}
+
+func NewTextChunk(text string) *LLMChunk {
+	return &LLMChunk{Kind: LLMChunkKindText, Text: text}
+}
+
+func NewToolCallChunk(delta *ToolCallDelta) *LLMChunk {
+	return &LLMChunk{Kind: LLMChunkKindToolCall, ToolCall: delta}
+}
+
+func NewUsageChunk(usage *LLMUsage) *LLMChunk {
+	return &LLMChunk{Kind: LLMChunkKindUsage, Usage: usage}
+}
@@ -222,6 +222,9 @@ func (s *StructuredRetriable) Retry(ctx context.Context, attempt int) (*ai.LLMRe
 	}
 
 	payload := ai.NewLLMResponse(ai.NewAssistantMessage(string(result)))
+	if response.Usage != nil {
+		payload.SetUsage(response.Usage)
+	}
 	s.events.OnResponse(ctx, s.request, payload, true)
 
 	return payload, nil
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Tools with side effects, e.g. booking a flight, can require a human to approve
+// each call before the agent executes it. The agent asks its approver for a
+// decision and suspends the run until one is available.
+
+// ErrApprovalPending is returned by approvers which don't have a decision yet
+var ErrApprovalPending = errors.New("tool call is waiting for approval")
+
+// ApprovalAction is the verdict of an approver over a tool call
+type ApprovalAction string
+
+const (
+	// ApprovalApprove executes the tool call as requested by the model
+	ApprovalApprove ApprovalAction = "approve"
+
+	// ApprovalReject skips the tool call, the model is told it was rejected
+	ApprovalReject ApprovalAction = "reject"
+
+	// ApprovalEdit executes the tool call with arguments changed by the approver
+	ApprovalEdit ApprovalAction = "edit"
+)
+
+// ApprovalDecision is the answer of an approver. Args are required when editing,
+// Reason is passed to the model when rejecting.
+type ApprovalDecision struct {
+	Action ApprovalAction  `json:"action"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Reason string          `json:"reason,omitempty"`
+}
+
+func Approve() *ApprovalDecision {
+	return &ApprovalDecision{Action: ApprovalApprove}
+}
+
+func Reject(reason string) *ApprovalDecision {
+	return &ApprovalDecision{Action: ApprovalReject, Reason: reason}
+}
+
+func Edit(args json.RawMessage) *ApprovalDecision {
+	return &ApprovalDecision{Action: ApprovalEdit, Args: args}
+}
+
+// approvalTool marks the wrapped tool as requiring approval
+type approvalTool struct {
+	Tool
+}
+
+// RequireApproval wraps the tool so every call has to be approved before it's executed
+func RequireApproval(tool Tool) Tool {
+	return &approvalTool{Tool: tool}
+}
+
+func (t *approvalTool) RequiresApproval() bool {
+	return true
+}
+
+// Unwrap returns the wrapped tool
+func (t *approvalTool) Unwrap() Tool {
+	return t.Tool
+}
+
+// RequiresApproval reports whether calls of the tool have to be approved, tools opt in by
+// implementing RequiresApproval() bool. Wrappers exposing Unwrap() Tool are looked through.
+func RequiresApproval(tool Tool) bool {
+	for tool != nil {
+		if approval, ok := tool.(interface{ RequiresApproval() bool }); ok {
+			return approval.RequiresApproval()
+		}
+
+		wrapper, ok := tool.(interface{ Unwrap() Tool })
+		if !ok {
+			return false
+		}
+
+		tool = wrapper.Unwrap()
+	}
+
+	return false
+}
+
+// RequireApproval wraps tools matching the predicate with RequireApproval
+func (t Toolbox) RequireApproval(predicate func(Tool) bool) Toolbox {
+	tools := make(Toolbox, len(t))
+	for i, tool := range t {
+		if predicate(tool) && !RequiresApproval(tool) {
+			tool = RequireApproval(tool)
+		}
+
+		tools[i] = tool
+	}
+
+	return tools
+}
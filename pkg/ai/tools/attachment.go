@@ -0,0 +1,29 @@
+package tools
+
+import "context"
+
+// AttachmentType is the kind of content attached to a tool result
+type AttachmentType string
+
+const (
+	AttachmentImage AttachmentType = "image"
+	AttachmentFile  AttachmentType = "file"
+)
+
+// Attachment is content of a tool result which isn't text, e.g. an image or a file.
+// It carries either a URL or inline Data with its MIME type.
+type Attachment struct {
+	Type     AttachmentType `json:"type"`
+	URL      string         `json:"url,omitempty"`
+	Data     []byte         `json:"data,omitempty"`
+	MIMEType string         `json:"mime_type,omitempty"`
+	Filename string         `json:"filename,omitempty"`
+}
+
+// Attach adds attachments to the result of the call executing in the context. The agent
+// passes them to the model next to the result, without an execution info they are dropped.
+func Attach(ctx context.Context, attachments ...*Attachment) {
+	if info, ok := ExecutionInfoFrom(ctx); ok {
+		info.Attachments = append(info.Attachments, attachments...)
+	}
+}
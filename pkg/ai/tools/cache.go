@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ExecutionInfo is filled in by tools and their wrappers while a call executes.
+// The agent passes one in the context of every call to learn how it was served.
+type ExecutionInfo struct {
+	// Cached is set when the result came from a cache rather than the tool
+	Cached bool
+
+	// Attachments of the result, see Attach
+	Attachments []*Attachment
+}
+
+type executionInfoKey struct{}
+
+func WithExecutionInfo(ctx context.Context, info *ExecutionInfo) context.Context {
+	return context.WithValue(ctx, executionInfoKey{}, info)
+}
+
+func ExecutionInfoFrom(ctx context.Context) (*ExecutionInfo, bool) {
+	info, ok := ctx.Value(executionInfoKey{}).(*ExecutionInfo)
+	return info, ok
+}
+
+// ToolCache stores tool results. Implementations must be safe for concurrent use.
+type ToolCache interface {
+	// Get returns the result stored under the key, false when it's missing or expired
+	Get(ctx context.Context, key string) (json.RawMessage, bool, error)
+
+	// Set stores the result, ttl of 0 keeps it forever
+	Set(ctx context.Context, key string, result json.RawMessage, ttl time.Duration) error
+}
+
+// CacheKey identifies a call by the tool name and its arguments. Arguments are
+// canonicalized first, so key order and whitespace don't change the key.
+func CacheKey(name string, args json.RawMessage) (string, error) {
+	canonical, err := canonicalJSON(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize arguments of %s: %w", name, err)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write(canonical)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// canonicalJSON re-encodes the value, which sorts object keys and drops insignificant whitespace
+func canonicalJSON(data json.RawMessage) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("null"), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// CachedTool serves repeated calls with the same arguments from a cache. Only use it
+// for deterministic, read-only tools. Failed calls and calls with attachments are not
+// cached, as the cache keeps results only.
+type CachedTool struct {
+	Tool
+
+	cache ToolCache
+	ttl   time.Duration
+}
+
+type CachedToolOpts = func(*CachedTool)
+
+// WithCacheTTL sets how long results stay in the cache, defaults to forever
+func WithCacheTTL(ttl time.Duration) CachedToolOpts {
+	return func(t *CachedTool) {
+		t.ttl = ttl
+	}
+}
+
+func NewCachedTool(tool Tool, cache ToolCache, opts ...CachedToolOpts) *CachedTool {
+	t := &CachedTool{Tool: tool, cache: cache}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func (t *CachedTool) Execute(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	key, err := CacheKey(t.Name(), args)
+	if err != nil {
+		return nil, err
+	}
+
+	if result, ok, err := t.cache.Get(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to read cached result of %s: %w", t.Name(), err)
+	} else if ok {
+		if info, ok := ExecutionInfoFrom(ctx); ok {
+			info.Cached = true
+		}
+
+		return result, nil
+	}
+
+	// Observe attachments of the call, passing them on to the caller
+	inner := &ExecutionInfo{}
+	result, err := t.Tool.Execute(WithExecutionInfo(ctx, inner), args)
+	if err != nil {
+		return nil, err
+	}
+
+	Attach(ctx, inner.Attachments...)
+	if info, ok := ExecutionInfoFrom(ctx); ok && inner.Cached {
+		info.Cached = true
+	}
+
+	if len(inner.Attachments) > 0 {
+		return result, nil
+	}
+
+	if err := t.cache.Set(ctx, key, result, t.ttl); err != nil {
+		return nil, fmt.Errorf("failed to cache result of %s: %w", t.Name(), err)
+	}
+
+	return result, nil
+}
+
+// Unwrap returns the wrapped tool
+func (t *CachedTool) Unwrap() Tool {
+	return t.Tool
+}
+
+// cacheEntry is a stored result, zero ExpiresAt never expires
+type cacheEntry struct {
+	Result    json.RawMessage `json:"result"`
+	ExpiresAt time.Time       `json:"expires_at,omitzero"`
+}
+
+func newCacheEntry(result json.RawMessage, ttl time.Duration, now time.Time) *cacheEntry {
+	entry := &cacheEntry{Result: result}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+
+	return entry
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// MemoryToolCache keeps results in memory for the lifetime of the process
+type MemoryToolCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+	now     func() time.Time
+}
+
+func NewMemoryToolCache() *MemoryToolCache {
+	return &MemoryToolCache{entries: map[string]*cacheEntry{}, now: time.Now}
+}
+
+func (c *MemoryToolCache) Get(ctx context.Context, key string) (json.RawMessage, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.expired(c.now()) {
+		return nil, false, nil
+	}
+
+	return entry.Result, true, nil
+}
+
+func (c *MemoryToolCache) Set(ctx context.Context, key string, result json.RawMessage, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = newCacheEntry(result, ttl, c.now())
+	return nil
+}
+
+// FileToolCache keeps every result in its own JSON file under a directory, so results
+// survive across processes, e.g. repeated eval runs
+type FileToolCache struct {
+	dir string
+	now func() time.Time
+}
+
+func NewFileToolCache(dir string) *FileToolCache {
+	return &FileToolCache{dir: dir, now: time.Now}
+}
+
+func (c *FileToolCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *FileToolCache) Get(ctx context.Context, key string) (json.RawMessage, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		// Corrupted entries are treated as missing and overwritten by the next Set
+		return nil, false, nil
+	}
+
+	if entry.expired(c.now()) {
+		return nil, false, nil
+	}
+
+	return entry.Result, true, nil
+}
+
+func (c *FileToolCache) Set(ctx context.Context, key string, result json.RawMessage, ttl time.Duration) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(newCacheEntry(result, ttl, c.now()))
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(key))
+}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type lookupInput struct {
+	Table  string `json:"table"`
+	Schema string `json:"schema"`
+}
+
+type lookupOutput struct {
+	Rows int `json:"rows"`
+}
+
+func newLookupTool(calls *int, err error) Tool {
+	return NewSimpleTool("lookup", "Look up row count", func(ctx context.Context, in *lookupInput) (*lookupOutput, error) {
+		*calls++
+		return &lookupOutput{Rows: 42}, err
+	})
+}
+
+func TestCacheKeyCanonicalizesArgs(t *testing.T) {
+	first, err := CacheKey("lookup", json.RawMessage(`{"table": "orders", "schema": "public"}`))
+	require.NoError(t, err)
+
+	second, err := CacheKey("lookup", json.RawMessage(`{"schema":"public","table":"orders"}`))
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	other, err := CacheKey("describe", json.RawMessage(`{"schema":"public","table":"orders"}`))
+	require.NoError(t, err)
+	require.NotEqual(t, first, other)
+
+	_, err = CacheKey("lookup", json.RawMessage(`{"table":`))
+	require.Error(t, err)
+}
+
+func TestCachedTool(t *testing.T) {
+	caches := map[string]func() ToolCache{
+		"memory": func() ToolCache { return NewMemoryToolCache() },
+		"file": func() ToolCache {
+			dir := t.TempDir()
+			return NewFileToolCache(dir)
+		},
+	}
+
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			calls := 0
+			tool := NewCachedTool(newLookupTool(&calls, nil), newCache())
+
+			info := &ExecutionInfo{}
+			result, err := tool.Execute(WithExecutionInfo(ctx, info), json.RawMessage(`{"table": "orders", "schema": "public"}`))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"rows": 42}`, string(result))
+			require.False(t, info.Cached)
+
+			info = &ExecutionInfo{}
+			result, err = tool.Execute(WithExecutionInfo(ctx, info), json.RawMessage(`{"schema": "public", "table": "orders"}`))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"rows": 42}`, string(result))
+			require.True(t, info.Cached)
+			require.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestCachedToolTTL(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	memory := NewMemoryToolCache()
+	memory.now = clock
+
+	file := NewFileToolCache(t.TempDir())
+	file.now = clock
+
+	for name, cache := range map[string]ToolCache{"memory": memory, "file": file} {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			tool := NewCachedTool(newLookupTool(&calls, nil), cache, WithCacheTTL(time.Minute))
+			args := json.RawMessage(`{"table": "orders"}`)
+
+			for range 2 {
+				_, err := tool.Execute(context.Background(), args)
+				require.NoError(t, err)
+			}
+			require.Equal(t, 1, calls)
+
+			now = now.Add(time.Minute)
+			_, err := tool.Execute(context.Background(), args)
+			require.NoError(t, err)
+			require.Equal(t, 2, calls)
+		})
+	}
+}
+
+func TestCachedToolSkipsFailures(t *testing.T) {
+	calls := 0
+	tool := NewCachedTool(newLookupTool(&calls, errors.New("warehouse unavailable")), NewMemoryToolCache())
+
+	for range 2 {
+		_, err := tool.Execute(context.Background(), json.RawMessage(`{"table": "orders"}`))
+		require.Error(t, err)
+	}
+
+	require.Equal(t, 2, calls)
+}
+
+func TestCachedToolSkipsAttachments(t *testing.T) {
+	calls := 0
+	chart := NewSimpleTool("chart", "Chart row counts", func(ctx context.Context, in *lookupInput) (*lookupOutput, error) {
+		calls++
+		Attach(ctx, &Attachment{Type: AttachmentImage, Data: []byte("png"), MIMEType: "image/png"})
+		return &lookupOutput{Rows: 42}, nil
+	})
+	tool := NewCachedTool(chart, NewMemoryToolCache())
+
+	for range 2 {
+		info := &ExecutionInfo{}
+		result, err := tool.Execute(WithExecutionInfo(context.Background(), info), json.RawMessage(`{"table": "orders"}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"rows": 42}`, string(result))
+		require.False(t, info.Cached)
+		require.Len(t, info.Attachments, 1)
+	}
+
+	require.Equal(t, 2, calls)
+}
+
+func TestFileToolCacheSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	args := json.RawMessage(`{"table": "orders"}`)
+
+	_, err := NewCachedTool(newLookupTool(&calls, nil), NewFileToolCache(dir)).Execute(context.Background(), args)
+	require.NoError(t, err)
+
+	_, err = NewCachedTool(newLookupTool(&calls, nil), NewFileToolCache(dir)).Execute(context.Background(), args)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ListResourcesName = "list_resources"
+	ReadResourceName  = "read_resource"
+)
+
+// MCPResource describes a resource offered by an MCP server
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+}
+
+type ListResourcesInput struct{}
+
+type ListResourcesOutput struct {
+	Resources []*MCPResource `json:"resources"`
+}
+
+type ReadResourceInput struct {
+	URI string `json:"uri" jsonschema:"required" jsonschema_description:"URI of the resource, as listed by list_resources"`
+}
+
+// MCPResourceContent is one content of a read resource. Text is returned inline,
+// binary content is attached to the result instead.
+type MCPResourceContent struct {
+	URI      string `json:"uri"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Attached bool   `json:"attached,omitempty"`
+}
+
+type ReadResourceOutput struct {
+	Contents []*MCPResourceContent `json:"contents"`
+}
+
+// GetMCPResourceTools returns list_resources and read_resource tools, letting the model
+// browse and read resources of the session's server on its own
+func GetMCPResourceTools(session *mcp.ClientSession) Toolbox {
+	return NewToolbox(
+		NewSimpleTool(ListResourcesName, "List resources offered by the MCP server, e.g. files, schemas or documents",
+			func(ctx context.Context, input *ListResourcesInput) (*ListResourcesOutput, error) {
+				return listMCPResources(ctx, session)
+			}),
+		NewSimpleTool(ReadResourceName, "Read a resource of the MCP server by its URI",
+			func(ctx context.Context, input *ReadResourceInput) (*ReadResourceOutput, error) {
+				return readMCPResource(ctx, session, input.URI)
+			}),
+	)
+}
+
+func listMCPResources(ctx context.Context, session *mcp.ClientSession) (*ListResourcesOutput, error) {
+	output := &ListResourcesOutput{Resources: []*MCPResource{}}
+	for resource, err := range session.Resources(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resources: %w", err)
+		}
+
+		output.Resources = append(output.Resources, &MCPResource{
+			URI:         resource.URI,
+			Name:        resource.Name,
+			Description: resource.Description,
+			MIMEType:    resource.MIMEType,
+		})
+	}
+
+	return output, nil
+}
+
+func readMCPResource(ctx context.Context, session *mcp.ClientSession, uri string) (*ReadResourceOutput, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("uri is required")
+	}
+
+	result, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s: %w", uri, err)
+	}
+
+	output := &ReadResourceOutput{Contents: []*MCPResourceContent{}}
+	for _, contents := range result.Contents {
+		content := &MCPResourceContent{URI: contents.URI, MIMEType: contents.MIMEType, Text: contents.Text}
+
+		if contents.Blob != nil {
+			if attachment := MCPResourceAttachment(contents); attachment != nil {
+				Attach(ctx, attachment)
+				content.Attached = true
+			}
+		}
+
+		output.Contents = append(output.Contents, content)
+	}
+
+	return output, nil
+}
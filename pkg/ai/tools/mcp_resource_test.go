@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPResourceTools(t *testing.T) {
+	ctx := context.Background()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v1.0.0"}, nil)
+	server.AddResource(&mcp.Resource{URI: "file:///schemas/orders.sql", Name: "orders", Description: "Schema of orders", MIMEType: "application/sql"},
+		func(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: request.Params.URI, MIMEType: "application/sql", Text: "CREATE TABLE orders (id INT)"},
+			}}, nil
+		})
+	server.AddResource(&mcp.Resource{URI: "file:///charts/orders.png", Name: "orders chart", MIMEType: "image/png"},
+		func(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: request.Params.URI, MIMEType: "image/png", Blob: []byte("png")},
+			}}, nil
+		})
+
+	toolbox := GetMCPResourceTools(connectMCP(t, server))
+	listResources, err := toolbox.FindTool(ListResourcesName)
+	require.NoError(t, err)
+	readResource, err := toolbox.FindTool(ReadResourceName)
+	require.NoError(t, err)
+
+	result, err := listResources.Execute(ctx, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"resources": [
+		{"uri": "file:///charts/orders.png", "name": "orders chart", "mime_type": "image/png"},
+		{"uri": "file:///schemas/orders.sql", "name": "orders", "description": "Schema of orders", "mime_type": "application/sql"}
+	]}`, string(result))
+
+	result, err = readResource.Execute(ctx, json.RawMessage(`{"uri": "file:///schemas/orders.sql"}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"contents": [{"uri": "file:///schemas/orders.sql", "mime_type": "application/sql", "text": "CREATE TABLE orders (id INT)"}]}`, string(result))
+
+	info := &ExecutionInfo{}
+	result, err = readResource.Execute(WithExecutionInfo(ctx, info), json.RawMessage(`{"uri": "file:///charts/orders.png"}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"contents": [{"uri": "file:///charts/orders.png", "mime_type": "image/png", "attached": true}]}`, string(result))
+	require.Equal(t, []*Attachment{{Type: AttachmentImage, Data: []byte("png"), MIMEType: "image/png", Filename: "orders.png"}}, info.Attachments)
+
+	_, err = readResource.Execute(ctx, json.RawMessage(`{"uri": "file:///missing"}`))
+	require.ErrorContains(t, err, "failed to read resource file:///missing")
+}
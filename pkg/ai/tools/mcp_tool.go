@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 type MCPTool struct {
@@ -38,6 +41,14 @@ func NewFromMCPTool(session *mcp.ClientSession, tool *mcp.Tool) *MCPTool {
 	}
 }
 
+// WithSession returns a copy of the tool calling it through the session, e.g. after reconnecting to the server
+func (t *MCPTool) WithSession(session *mcp.ClientSession) *MCPTool {
+	tool := *t
+	tool.session = session
+
+	return &tool
+}
+
 func (t *MCPTool) Name() string {
 	return t.Name_
 }
@@ -54,6 +65,21 @@ func (t *MCPTool) OutputSchemaRaw() json.RawMessage {
 	return t.OutputSchema
 }
 
+// MCPToolError is returned when the server reports the call failed, Message holds
+// the text content of the result
+type MCPToolError struct {
+	Tool    string
+	Message string
+}
+
+func (e *MCPToolError) Error() string {
+	return fmt.Sprintf("tool %s failed: %s", e.Tool, e.Message)
+}
+
+// Execute calls the tool on the server. Structured content is returned as it is once
+// validated against the output schema, otherwise text parts of the content are joined.
+// Text which isn't JSON is returned as a JSON string. Images and resources are attached
+// to the result, see Attach.
 func (t *MCPTool) Execute(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
 	res, err := t.session.CallTool(ctx, &mcp.CallToolParams{
 		Name:      t.Name(),
@@ -64,18 +90,189 @@ func (t *MCPTool) Execute(ctx context.Context, args json.RawMessage) (json.RawMe
 		return nil, err
 	}
 
-	if len(res.Content) == 0 {
+	text, attachments := ConvertMCPContent(res.Content)
+
+	if res.IsError {
+		return nil, &MCPToolError{Tool: t.Name(), Message: text}
+	}
+
+	if res.StructuredContent != nil {
+		structured, err := json.Marshal(res.StructuredContent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal structured content of %s: %w", t.Name(), err)
+		}
+
+		if err := t.validateOutput(structured); err != nil {
+			return nil, err
+		}
+
+		Attach(ctx, attachments...)
+		return structured, nil
+	}
+
+	if text == "" && len(attachments) == 0 {
 		return nil, fmt.Errorf("no content returned from tool")
 	}
 
-	last := res.Content[len(res.Content)-1]
+	Attach(ctx, attachments...)
+
+	if text == "" {
+		return json.Marshal(fmt.Sprintf("Tool returned %d attachments.", len(attachments)))
+	}
+
+	if json.Valid([]byte(text)) {
+		return json.RawMessage(text), nil
+	}
+
+	return json.Marshal(text)
+}
+
+// validateOutput checks structured content against the output schema, if the tool has one
+func (t *MCPTool) validateOutput(output json.RawMessage) error {
+	if len(t.OutputSchema) == 0 || string(t.OutputSchema) == "null" {
+		return nil
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(t.OutputSchema))
+	if err != nil {
+		return fmt.Errorf("failed to compile output schema of %s: %w", t.Name(), err)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(output))
+	if err != nil {
+		return fmt.Errorf("failed to validate structured content of %s: %w", t.Name(), err)
+	}
+
+	if !result.Valid() {
+		var errs []string
+		for _, err := range result.Errors() {
+			errs = append(errs, err.String())
+		}
+
+		return fmt.Errorf("structured content of %s does not match its output schema: %s", t.Name(), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// ConvertMCPContent joins text parts of the content and turns images and embedded resources
+// into attachments. Content models can't take as attachments is described in the text instead:
+// links to resources, which providers can't fetch, and audio.
+func ConvertMCPContent(content []mcp.Content) (string, []*Attachment) {
+	var texts []string
+	var attachments []*Attachment
+
+	for _, part := range content {
+		switch c := part.(type) {
+		case *mcp.TextContent:
+			texts = append(texts, c.Text)
+
+		case *mcp.ImageContent:
+			attachments = append(attachments, &Attachment{Type: AttachmentImage, Data: c.Data, MIMEType: c.MIMEType})
+
+		case *mcp.AudioContent:
+			texts = append(texts, fmt.Sprintf("[%s audio omitted, audio is not supported]", c.MIMEType))
+
+		case *mcp.ResourceLink:
+			texts = append(texts, resourceLinkText(c))
+
+		case *mcp.EmbeddedResource:
+			if c.Resource == nil {
+				continue
+			}
+
+			if attachment := MCPResourceAttachment(c.Resource); attachment != nil {
+				attachments = append(attachments, attachment)
+			} else {
+				texts = append(texts, fmt.Sprintf("[resource %s is empty]", c.Resource.URI))
+			}
+		}
+	}
+
+	return strings.Join(texts, "\n"), attachments
+}
+
+// MCPResourceAttachment turns contents of a resource into an attachment, nil for empty contents.
+// Text is attached as a text file whatever its MIME type, e.g. application/sql, so models read it inline.
+func MCPResourceAttachment(contents *mcp.ResourceContents) *Attachment {
+	filename := path.Base(contents.URI)
+
+	if contents.Blob == nil {
+		if contents.Text == "" {
+			return nil
+		}
+
+		mimeType := contents.MIMEType
+		if !isTextMIMEType(mimeType) {
+			mimeType = "text/plain"
+		}
+
+		return &Attachment{Type: AttachmentFile, Data: []byte(contents.Text), MIMEType: mimeType, Filename: filename}
+	}
+
+	if len(contents.Blob) == 0 {
+		return nil
+	}
+
+	return &Attachment{Type: attachmentType(contents.MIMEType), Data: contents.Blob, MIMEType: contents.MIMEType, Filename: filename}
+}
+
+// MCPAttachmentContent turns an attachment into MCP content, the reverse of ConvertMCPContent.
+// Images become image content, files embedded resources, text files as text. Attachments
+// without data are linked by their URL.
+func MCPAttachmentContent(attachment *Attachment) mcp.Content {
+	filename := attachment.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+
+	if len(attachment.Data) == 0 {
+		return &mcp.ResourceLink{URI: attachment.URL, Name: filename, MIMEType: attachment.MIMEType}
+	}
+
+	if attachment.Type == AttachmentImage {
+		return &mcp.ImageContent{Data: attachment.Data, MIMEType: attachment.MIMEType}
+	}
+
+	contents := &mcp.ResourceContents{URI: "attachment:///" + filename, MIMEType: attachment.MIMEType}
+	if isTextMIMEType(attachment.MIMEType) {
+		contents.Text = string(attachment.Data)
+	} else {
+		contents.Blob = attachment.Data
+	}
+
+	return &mcp.EmbeddedResource{Resource: contents}
+}
+
+func resourceLinkText(link *mcp.ResourceLink) string {
+	text := "Resource " + link.URI
+	if link.Name != "" {
+		text += " (" + link.Name + ")"
+	}
+	if link.MIMEType != "" {
+		text += ", " + link.MIMEType
+	}
+
+	return text
+}
+
+// isTextMIMEType matches the MIME types passed to models inline as text, see ai.ContentPart.IsTextFile
+func isTextMIMEType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch mimeType {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml", "application/x-ndjson":
+		return true
+	}
+
+	return strings.HasPrefix(mimeType, "text/")
+}
 
-	typed, ok := last.(*mcp.TextContent)
-	if !ok {
-		return nil, fmt.Errorf("unsupported MCP response content type %T, must be text", last)
+func attachmentType(mimeType string) AttachmentType {
+	if strings.HasPrefix(mimeType, "image/") {
+		return AttachmentImage
 	}
 
-	return json.RawMessage(typed.Text), nil
+	return AttachmentFile
 }
 
 func GetMCPTools(ctx context.Context, session *mcp.ClientSession) Toolbox {
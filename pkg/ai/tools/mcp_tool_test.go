@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+// serveMCP serves a single tool returning the result over an in-memory transport
+func serveMCP(t *testing.T, tool *mcp.Tool, result *mcp.CallToolResult) *MCPTool {
+	ctx := context.Background()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v1.0.0"}, nil)
+	if tool.InputSchema == nil {
+		tool.InputSchema = &jsonschema.Schema{Type: "object"}
+	}
+	server.AddTool(tool, func(ctx context.Context, request *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return result, nil
+	})
+
+	toolbox := GetMCPTools(ctx, connectMCP(t, server))
+	require.Len(t, toolbox, 1)
+
+	return toolbox[0].(*MCPTool)
+}
+
+// connectMCP connects a client to the server over an in-memory transport
+func connectMCP(t *testing.T, server *mcp.Server) *mcp.ClientSession {
+	ctx := context.Background()
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v1.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { session.Close() })
+
+	return session
+}
+
+func TestMCPToolStructuredContent(t *testing.T) {
+	outputSchema := &jsonschema.Schema{
+		Type:       "object",
+		Required:   []string{"rows"},
+		Properties: map[string]*jsonschema.Schema{"rows": {Type: "integer"}},
+	}
+
+	tool := serveMCP(t, &mcp.Tool{Name: "count", OutputSchema: outputSchema}, &mcp.CallToolResult{
+		Content:           []mcp.Content{&mcp.TextContent{Text: "There are 42 rows."}},
+		StructuredContent: map[string]any{"rows": 42},
+	})
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rows": 42}`, string(result))
+
+	invalid := serveMCP(t, &mcp.Tool{Name: "count", OutputSchema: outputSchema}, &mcp.CallToolResult{
+		Content:           []mcp.Content{&mcp.TextContent{Text: "There are many rows."}},
+		StructuredContent: map[string]any{"rows": "many"},
+	})
+
+	_, err = invalid.Execute(context.Background(), json.RawMessage(`{}`))
+	require.ErrorContains(t, err, "structured content of count does not match its output schema")
+}
+
+func TestMCPToolContent(t *testing.T) {
+	tool := serveMCP(t, &mcp.Tool{Name: "describe"}, &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: "Table orders has 42 rows."},
+			&mcp.ImageContent{Data: []byte("png"), MIMEType: "image/png"},
+			&mcp.TextContent{Text: "Chart of daily rows attached."},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///schemas/orders.sql", MIMEType: "application/sql", Text: "CREATE TABLE orders (id INT)"}},
+			&mcp.ResourceLink{URI: "https://example.com/orders.csv", Name: "orders.csv", MIMEType: "text/csv"},
+			&mcp.AudioContent{Data: []byte("wav"), MIMEType: "audio/wav"},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///schemas/empty.sql", MIMEType: "application/sql"}},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///data/orders.csv", MIMEType: "text/csv", Text: "id,amount"}},
+		},
+	})
+
+	info := &ExecutionInfo{}
+	result, err := tool.Execute(WithExecutionInfo(context.Background(), info), json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, `"Table orders has 42 rows.\nChart of daily rows attached.\nResource https://example.com/orders.csv (orders.csv), text/csv\n[audio/wav audio omitted, audio is not supported]\n[resource file:///schemas/empty.sql is empty]"`, string(result))
+	require.Equal(t, []*Attachment{
+		{Type: AttachmentImage, Data: []byte("png"), MIMEType: "image/png"},
+		{Type: AttachmentFile, Data: []byte("CREATE TABLE orders (id INT)"), MIMEType: "text/plain", Filename: "orders.sql"},
+		{Type: AttachmentFile, Data: []byte("id,amount"), MIMEType: "text/csv", Filename: "orders.csv"},
+	}, info.Attachments)
+
+	// JSON text is passed through as it is
+	tool = serveMCP(t, &mcp.Tool{Name: "count"}, &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: `{"rows": 42}`}},
+	})
+
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"rows": 42}`, string(result))
+}
+
+func TestMCPToolError(t *testing.T) {
+	tool := serveMCP(t, &mcp.Tool{Name: "count"}, &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: "table orderz does not exist"}},
+		IsError: true,
+	})
+
+	_, err := tool.Execute(context.Background(), json.RawMessage(`{"table": "orderz"}`))
+
+	var toolErr *MCPToolError
+	require.ErrorAs(t, err, &toolErr)
+	require.EqualError(t, err, "tool count failed: table orderz does not exist")
+}
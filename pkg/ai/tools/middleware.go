@@ -0,0 +1,297 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a tool to add behaviour around its execution, e.g. timeouts or logging
+type Middleware func(Tool) Tool
+
+// Wrap applies middlewares to the tool, the first middleware is the outermost one
+func Wrap(tool Tool, middlewares ...Middleware) Tool {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		tool = middlewares[i](tool)
+	}
+
+	return tool
+}
+
+// Use applies middlewares to every tool of the toolbox, the first middleware is the outermost one
+func (t Toolbox) Use(middlewares ...Middleware) Toolbox {
+	tools := make(Toolbox, len(t))
+	for i, tool := range t {
+		tools[i] = Wrap(tool, middlewares...)
+	}
+
+	return tools
+}
+
+// ExecuteFunc is the signature of Tool.Execute
+type ExecuteFunc func(ctx context.Context, args json.RawMessage) (json.RawMessage, error)
+
+// middlewareTool replaces Execute of the wrapped tool, everything else is forwarded
+type middlewareTool struct {
+	Tool
+	execute ExecuteFunc
+}
+
+// NewMiddleware builds a middleware from a function around Execute of the wrapped tool
+func NewMiddleware(around func(tool Tool, next ExecuteFunc) ExecuteFunc) Middleware {
+	return func(tool Tool) Tool {
+		return &middlewareTool{Tool: tool, execute: around(tool, tool.Execute)}
+	}
+}
+
+func (t *middlewareTool) Execute(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	return t.execute(ctx, args)
+}
+
+// Unwrap returns the wrapped tool
+func (t *middlewareTool) Unwrap() Tool {
+	return t.Tool
+}
+
+// TIMEOUT
+
+// Timeout cancels calls running longer than the timeout. Tools have to respect
+// the context for the call to actually stop.
+func Timeout(timeout time.Duration) Middleware {
+	return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			result, err := next(ctx, args)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, Transient(fmt.Errorf("%s timed out after %s: %w", tool.Name(), timeout, err))
+			}
+
+			return result, err
+		}
+	})
+}
+
+// RETRY
+
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// Transient marks an error as temporary, calls failing with it are retried by Retry
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &transientError{err: err}
+}
+
+// IsTransient reports whether the error is worth retrying: errors marked by Transient
+// and network timeouts
+func IsTransient(err error) bool {
+	var transient *transientError
+	if errors.As(err, &transient) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryPolicy configures Retry. Unlike the correction of tool calls done by the agent,
+// retries repeat the call with the same arguments, so they only help with transient failures.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Retryable decides which errors are retried, defaults to IsTransient
+	Retryable func(error) bool
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2.0,
+		Retryable:      IsTransient,
+	}
+}
+
+// Retry repeats calls failing with a retryable error, backing off exponentially between attempts.
+// Only attachments of the successful attempt are passed on to the caller.
+func Retry(policy *RetryPolicy) Middleware {
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			backoff := policy.InitialBackoff
+
+			for attempt := 1; ; attempt++ {
+				inner := &ExecutionInfo{}
+				result, err := next(WithExecutionInfo(ctx, inner), args)
+				if err == nil {
+					Attach(ctx, inner.Attachments...)
+					if info, ok := ExecutionInfoFrom(ctx); ok && inner.Cached {
+						info.Cached = true
+					}
+
+					return result, nil
+				}
+
+				if attempt >= policy.MaxAttempts || !retryable(err) {
+					return nil, err
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil, err
+				case <-time.After(backoff):
+				}
+
+				backoff = time.Duration(float64(backoff) * policy.Multiplier)
+				if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+					backoff = policy.MaxBackoff
+				}
+			}
+		}
+	})
+}
+
+// RATE LIMIT
+
+// tokenBucket allows rate calls per second on average with bursts of up to burst calls
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token, waiting until one is available
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// RateLimit limits every wrapped tool to rate calls per second, allowing bursts of up to burst calls.
+// Calls over the limit wait for their turn. A rate of 0 or less disables the limit, a burst below 1
+// is treated as 1.
+func RateLimit(rate float64, burst int) Middleware {
+	burst = max(burst, 1)
+
+	return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+		if rate <= 0 {
+			return next
+		}
+
+		bucket := &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+
+		return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			if err := bucket.wait(ctx); err != nil {
+				return nil, fmt.Errorf("%s rate limit: %w", tool.Name(), err)
+			}
+
+			return next(ctx, args)
+		}
+	})
+}
+
+// SIZE LIMIT
+
+// SizeLimitError is returned for arguments or results over the size limit
+type SizeLimitError struct {
+	Tool  string
+	What  string
+	Size  int
+	Limit int
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("%s of %s are %d bytes, over the limit of %d bytes", e.What, e.Tool, e.Size, e.Limit)
+}
+
+// SizeLimit fails calls whose arguments or results are larger than the limits in bytes,
+// a limit of 0 disables the check
+func SizeLimit(maxArgs, maxResult int) Middleware {
+	return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			if maxArgs > 0 && len(args) > maxArgs {
+				return nil, &SizeLimitError{Tool: tool.Name(), What: "arguments", Size: len(args), Limit: maxArgs}
+			}
+
+			result, err := next(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+
+			if maxResult > 0 && len(result) > maxResult {
+				return nil, &SizeLimitError{Tool: tool.Name(), What: "results", Size: len(result), Limit: maxResult}
+			}
+
+			return result, nil
+		}
+	})
+}
+
+// LOGGING
+
+// Logging logs every call with its duration and outcome
+func Logging(logger *slog.Logger) Middleware {
+	return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			started := time.Now()
+			logger.DebugContext(ctx, "tool call started", "tool", tool.Name(), "args", string(args))
+
+			result, err := next(ctx, args)
+			if err != nil {
+				logger.WarnContext(ctx, "tool call failed", "tool", tool.Name(), "duration", time.Since(started), "error", err)
+				return nil, err
+			}
+
+			logger.InfoContext(ctx, "tool call finished", "tool", tool.Name(), "duration", time.Since(started), "result_bytes", len(result))
+			return result, nil
+		}
+	})
+}
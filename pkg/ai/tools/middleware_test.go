@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type echoInput struct {
+	Text string `json:"text"`
+}
+
+// flakyTool fails with the given errors before echoing its input
+func flakyTool(calls *int, errs ...error) Tool {
+	return NewSimpleTool("echo", "Echo the text", func(ctx context.Context, in *echoInput) (*echoInput, error) {
+		*calls++
+		if *calls <= len(errs) {
+			return nil, errs[*calls-1]
+		}
+
+		return in, nil
+	})
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return NewMiddleware(func(tool Tool, next ExecuteFunc) ExecuteFunc {
+			return func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+				order = append(order, name)
+				return next(ctx, args)
+			}
+		})
+	}
+
+	calls := 0
+	toolbox := NewToolbox(flakyTool(&calls)).Use(trace("outer"), trace("inner"))
+
+	_, err := toolbox[0].Execute(context.Background(), json.RawMessage(`{"text": "hi"}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"outer", "inner"}, order)
+	require.Equal(t, "echo", toolbox[0].Name())
+}
+
+func TestMiddlewareForwardsApproval(t *testing.T) {
+	calls := 0
+	tool := Wrap(RequireApproval(flakyTool(&calls)), Timeout(time.Second), Logging(slog.Default()))
+
+	require.True(t, RequiresApproval(tool))
+	require.False(t, RequiresApproval(Wrap(flakyTool(&calls), Timeout(time.Second))))
+}
+
+func TestTimeout(t *testing.T) {
+	slow := NewSimpleTool("slow", "Slow tool", func(ctx context.Context, in *echoInput) (*echoInput, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_, err := Wrap(slow, Timeout(10*time.Millisecond)).Execute(context.Background(), json.RawMessage(`{}`))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.True(t, IsTransient(err))
+}
+
+func TestRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+	t.Run("transient", func(t *testing.T) {
+		calls := 0
+		tool := Wrap(flakyTool(&calls, Transient(errors.New("connection reset")), Transient(errors.New("connection reset"))), Retry(policy))
+
+		result, err := tool.Execute(context.Background(), json.RawMessage(`{"text": "hi"}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"text": "hi"}`, string(result))
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		calls := 0
+		failures := []error{Transient(errors.New("a")), Transient(errors.New("b")), Transient(errors.New("c"))}
+
+		_, err := Wrap(flakyTool(&calls, failures...), Retry(policy)).Execute(context.Background(), json.RawMessage(`{}`))
+		require.ErrorContains(t, err, "c")
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		calls := 0
+		chart := NewSimpleTool("chart", "Draw a chart", func(ctx context.Context, in *echoInput) (*echoInput, error) {
+			calls++
+			Attach(ctx, &Attachment{Type: AttachmentImage, Data: []byte{byte(calls)}, MIMEType: "image/png"})
+			if calls == 1 {
+				return nil, Transient(errors.New("connection reset"))
+			}
+
+			return in, nil
+		})
+
+		// Attachment of the failed attempt is dropped
+		info := &ExecutionInfo{}
+		_, err := Wrap(chart, Retry(policy)).Execute(WithExecutionInfo(context.Background(), info), json.RawMessage(`{}`))
+		require.NoError(t, err)
+		require.Equal(t, []*Attachment{{Type: AttachmentImage, Data: []byte{2}, MIMEType: "image/png"}}, info.Attachments)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		calls := 0
+
+		_, err := Wrap(flakyTool(&calls, errors.New("table not found")), Retry(policy)).Execute(context.Background(), json.RawMessage(`{}`))
+		require.ErrorContains(t, err, "table not found")
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	calls := 0
+	tool := Wrap(flakyTool(&calls), RateLimit(50, 2))
+
+	started := time.Now()
+	for range 4 {
+		_, err := tool.Execute(context.Background(), json.RawMessage(`{}`))
+		require.NoError(t, err)
+	}
+
+	// Burst of two is immediate, the other two wait 20ms each
+	require.GreaterOrEqual(t, time.Since(started), 35*time.Millisecond)
+
+	// Burst of 0 is treated as 1, the only token is taken and the next call waits until cancelled
+	limited := Wrap(flakyTool(&calls), RateLimit(0.001, 0))
+	_, err := limited.Execute(context.Background(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = limited.Execute(ctx, json.RawMessage(`{}`))
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Rate of 0 disables the limit
+	unlimited := Wrap(flakyTool(&calls), RateLimit(0, 1))
+	for range 3 {
+		_, err = unlimited.Execute(ctx, json.RawMessage(`{}`))
+		require.NoError(t, err)
+	}
+}
+
+func TestSizeLimit(t *testing.T) {
+	calls := 0
+	tool := Wrap(flakyTool(&calls), SizeLimit(32, 16))
+
+	_, err := tool.Execute(context.Background(), json.RawMessage(`{"text": "a very long argument to echo back"}`))
+
+	var sizeErr *SizeLimitError
+	require.ErrorAs(t, err, &sizeErr)
+	require.Equal(t, "arguments", sizeErr.What)
+	require.Equal(t, 0, calls)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{"text": "echo me"}`))
+	require.EqualError(t, err, "results of echo are 18 bytes, over the limit of 16 bytes")
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"text": "hi"}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"text": "hi"}`, string(result))
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	calls := 0
+	tool := Wrap(flakyTool(&calls, errors.New("boom")), Logging(logger))
+
+	_, err := tool.Execute(context.Background(), json.RawMessage(`{}`))
+	require.Error(t, err)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+
+	require.Contains(t, buf.String(), `msg="tool call started" tool=echo`)
+	require.Contains(t, buf.String(), `msg="tool call failed" tool=echo`)
+	require.Contains(t, buf.String(), `msg="tool call finished" tool=echo`)
+}
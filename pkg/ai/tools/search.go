@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ToolScorer ranks tools of a toolbox against a query, returning one score per tool.
+// Higher scores are better matches, tools scoring 0 or less don't match at all.
+type ToolScorer interface {
+	Score(ctx context.Context, query string, toolbox Toolbox) ([]float64, error)
+}
+
+// ToolMatch is a tool found by Toolbox.Search
+type ToolMatch struct {
+	Tool  Tool
+	Score float64
+}
+
+// Search returns up to limit tools matching the query best, best match first
+func (t Toolbox) Search(ctx context.Context, query string, limit int, scorer ToolScorer) ([]*ToolMatch, error) {
+	scores, err := scorer.Score(ctx, query, t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to score tools: %w", err)
+	}
+	if len(scores) != len(t) {
+		return nil, fmt.Errorf("failed to score tools: got %d scores for %d tools", len(scores), len(t))
+	}
+
+	var matches []*ToolMatch
+	for i, tool := range t {
+		if scores[i] > 0 {
+			matches = append(matches, &ToolMatch{Tool: tool, Score: scores[i]})
+		}
+	}
+
+	// Stable sort keeps the toolbox order between equal scores
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// LEXICAL
+
+// LexicalScorer ranks tools by BM25 over words of their name and description.
+// Words of the name count twice, they are the most telling part of a tool.
+type LexicalScorer struct{}
+
+func NewLexicalScorer() *LexicalScorer {
+	return &LexicalScorer{}
+}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+func (s *LexicalScorer) Score(ctx context.Context, query string, toolbox Toolbox) ([]float64, error) {
+	documents := make([]map[string]int, len(toolbox))
+	lengths := make([]int, len(toolbox))
+	frequency := map[string]int{}
+	total := 0
+
+	for i, tool := range toolbox {
+		name := tokenize(tool.Name())
+		words := append(append(name, name...), tokenize(tool.Description())...)
+
+		documents[i] = map[string]int{}
+		for _, word := range words {
+			if documents[i][word] == 0 {
+				frequency[word]++
+			}
+			documents[i][word]++
+		}
+
+		lengths[i] = len(words)
+		total += len(words)
+	}
+
+	scores := make([]float64, len(toolbox))
+	if total == 0 {
+		return scores, nil
+	}
+
+	averageLength := float64(total) / float64(len(toolbox))
+	n := float64(len(toolbox))
+
+	for _, word := range unique(tokenize(query)) {
+		idf := math.Log(1 + (n-float64(frequency[word])+0.5)/(float64(frequency[word])+0.5))
+
+		for i, document := range documents {
+			tf := float64(document[word])
+			if tf == 0 {
+				continue
+			}
+
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/averageLength))
+		}
+	}
+
+	return scores, nil
+}
+
+// stopWords are too common to tell tools apart, listed after plural folding, e.g. doe(s) and thi(s)
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "doe": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "me": true, "my": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"thi": true, "to": true, "what": true, "which": true, "with": true,
+}
+
+// tokenize splits text into lower case words, breaking up snake_case and camelCase
+// names. Plurals are folded naively by dropping a trailing s, stop words are left out.
+func tokenize(text string) []string {
+	var words []string
+	var word []rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+
+		w := string(word)
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+
+		if !stopWords[w] {
+			words = append(words, w)
+		}
+		word = word[:0]
+	}
+
+	var previous rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(previous) {
+				flush()
+			}
+			word = append(word, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+
+		previous = r
+	}
+	flush()
+
+	return words
+}
+
+func unique(words []string) []string {
+	seen := map[string]bool{}
+
+	var result []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			result = append(result, word)
+		}
+	}
+
+	return result
+}
+
+// EMBEDDING
+
+// Embedder turns texts into vectors, e.g. through an embeddings API. It returns one vector per text.
+type Embedder func(ctx context.Context, texts []string) ([][]float64, error)
+
+// EmbeddingScorer ranks tools by cosine similarity of their embedding to the embedding
+// of the query. Embeddings of tools are computed once and kept for later searches.
+type EmbeddingScorer struct {
+	embed Embedder
+
+	mu    sync.Mutex
+	cache map[string][]float64
+}
+
+func NewEmbeddingScorer(embed Embedder) *EmbeddingScorer {
+	return &EmbeddingScorer{embed: embed, cache: map[string][]float64{}}
+}
+
+func (s *EmbeddingScorer) Score(ctx context.Context, query string, toolbox Toolbox) ([]float64, error) {
+	texts := make([]string, len(toolbox))
+	for i, tool := range toolbox {
+		texts[i] = tool.Name() + ": " + tool.Description()
+	}
+
+	// Embed the query together with tools not seen before in a single call
+	s.mu.Lock()
+	missing := []string{query}
+	for _, text := range unique(texts) {
+		if _, ok := s.cache[text]; !ok {
+			missing = append(missing, text)
+		}
+	}
+	s.mu.Unlock()
+
+	vectors, err := s.embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(missing) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(vectors), len(missing))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, text := range missing[1:] {
+		s.cache[text] = vectors[i+1]
+	}
+
+	scores := make([]float64, len(toolbox))
+	for i, text := range texts {
+		scores[i] = cosine(vectors[0], s.cache[text])
+	}
+
+	return scores, nil
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
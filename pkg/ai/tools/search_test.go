@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func namedTool(name, description string) Tool {
+	return NewSimpleTool(name, description, func(ctx context.Context, in *lookupInput) (*lookupOutput, error) {
+		return &lookupOutput{}, nil
+	})
+}
+
+var warehouseTools = NewToolbox(
+	namedTool("list_tables", "List tables in a schema of the warehouse"),
+	namedTool("getTableColumns", "Get columns and their types of a table"),
+	namedTool("run_query", "Run a SQL query against the warehouse"),
+	namedTool("list_incidents", "List open data quality incidents"),
+	namedTool("send_slack_message", "Send a message to a Slack channel"),
+)
+
+func names(matches []*ToolMatch) []string {
+	var result []string
+	for _, match := range matches {
+		result = append(result, match.Tool.Name())
+	}
+	return result
+}
+
+func TestTokenize(t *testing.T) {
+	require.Equal(t, []string{"get", "table", "column"}, tokenize("getTableColumns"))
+	require.Equal(t, []string{"list", "open", "incident", "class"}, tokenize("list_open-incidents in THE class"))
+}
+
+func TestLexicalSearch(t *testing.T) {
+	matches, err := warehouseTools.Search(context.Background(), "which columns does the orders table have?", 2, NewLexicalScorer())
+	require.NoError(t, err)
+	require.Equal(t, []string{"getTableColumns", "list_tables"}, names(matches))
+
+	matches, err = warehouseTools.Search(context.Background(), "notify the team on slack", 5, NewLexicalScorer())
+	require.NoError(t, err)
+	require.Equal(t, []string{"send_slack_message"}, names(matches))
+
+	matches, err = warehouseTools.Search(context.Background(), "weather forecast", 5, NewLexicalScorer())
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func TestEmbeddingSearch(t *testing.T) {
+	var embedded []string
+
+	// Fake embedding with one dimension per topic
+	topics := []string{"slack", "incident", "query"}
+	embed := func(ctx context.Context, texts []string) ([][]float64, error) {
+		embedded = append(embedded, texts...)
+
+		vectors := make([][]float64, len(texts))
+		for i, text := range texts {
+			vectors[i] = make([]float64, len(topics))
+			for j, topic := range topics {
+				if strings.Contains(strings.ToLower(text), topic) {
+					vectors[i][j] = 1
+				}
+			}
+		}
+
+		return vectors, nil
+	}
+
+	scorer := NewEmbeddingScorer(embed)
+
+	matches, err := warehouseTools.Search(context.Background(), "open incidents", 1, scorer)
+	require.NoError(t, err)
+	require.Equal(t, []string{"list_incidents"}, names(matches))
+	require.Len(t, embedded, 6)
+
+	// Tools are embedded once, later searches embed the query only
+	matches, err = warehouseTools.Search(context.Background(), "post to slack", 1, scorer)
+	require.NoError(t, err)
+	require.Equal(t, []string{"send_slack_message"}, names(matches))
+	require.Len(t, embedded, 7)
+}
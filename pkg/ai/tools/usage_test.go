@@ -20,6 +20,11 @@ func TestToolUsage(t *testing.T) {
 			toolUsage: ForceTool("calculator"),
 			expected:  ToolUsageForced,
 		},
+		{
+			name:      "No tool selection",
+			toolUsage: NoToolSelection(),
+			expected:  ToolUsageNone,
+		},
 	}
 
 	for _, tt := range tests {
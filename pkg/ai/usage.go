@@ -2,6 +2,7 @@ package ai
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
@@ -11,18 +12,71 @@ type LLMUsageTokens struct {
 	PromptTokens     int64 `json:"prompt_tokens"`
 	CompletionTokens int64 `json:"completion_tokens"`
 	TotalTokens      int64 `json:"total_tokens"`
+
+	// CachedPromptTokens is the part of PromptTokens served from the provider's prompt cache
+	CachedPromptTokens int64 `json:"cached_prompt_tokens,omitempty"`
 }
 
 type LLMUsage struct {
 	LLMUsageTokens `json:",inline"`
 	Turns          int64 `json:"turns"`
 	ToolCalls      []*LLMUsageToolCall
+
+	// Breakdown holds usage of individual turns, in the order they happened
+	Breakdown []*LLMUsageTurn `json:"breakdown,omitempty"`
+}
+
+// LLMUsageTurn is usage of a single LLM call. Turn and ToolCalls are set
+// when the call was made by an agent loop.
+type LLMUsageTurn struct {
+	Turn           int     `json:"turn"`
+	Model          ModelId `json:"model,omitempty"`
+	LLMUsageTokens `json:",inline"`
+	ToolCalls      int `json:"tool_calls"`
 }
 
+// LLMUsageToolCall records an executed tool call, Cached is set when
+// the result was served from a cache instead of the tool
 type LLMUsageToolCall struct {
-	Name  string          `json:"name"`
-	Args  json.RawMessage `json:"args"`
-	Error error           `json:"error"`
+	Name   string          `json:"name"`
+	Args   json.RawMessage `json:"args"`
+	Error  error           `json:"error"`
+	Cached bool            `json:"cached,omitempty"`
+}
+
+type llmUsageToolCallJSON struct {
+	Name   string          `json:"name"`
+	Args   json.RawMessage `json:"args"`
+	Error  *string         `json:"error"`
+	Cached bool            `json:"cached,omitempty"`
+}
+
+// MarshalJSON encodes the error as its message, errors don't marshal on their own
+func (c *LLMUsageToolCall) MarshalJSON() ([]byte, error) {
+	encoded := llmUsageToolCallJSON{Name: c.Name, Args: c.Args, Cached: c.Cached}
+	if c.Error != nil {
+		message := c.Error.Error()
+		encoded.Error = &message
+	}
+
+	return json.Marshal(encoded)
+}
+
+func (c *LLMUsageToolCall) UnmarshalJSON(data []byte) error {
+	var decoded llmUsageToolCallJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	c.Name = decoded.Name
+	c.Args = decoded.Args
+	c.Cached = decoded.Cached
+	c.Error = nil
+	if decoded.Error != nil {
+		c.Error = errors.New(*decoded.Error)
+	}
+
+	return nil
 }
 
 func NewLLMUsage(promptTokens, completionTokens, totalTokens int64) *LLMUsage {
@@ -36,8 +90,15 @@ func NewLLMUsage(promptTokens, completionTokens, totalTokens int64) *LLMUsage {
 	}
 }
 
+// ForModel records the usage as a single call to the given model, so it can be priced
+func (u *LLMUsage) ForModel(model ModelId) *LLMUsage {
+	u.Breakdown = []*LLMUsageTurn{{Model: model, LLMUsageTokens: u.LLMUsageTokens}}
+	return u
+}
+
 func (u *LLMUsage) Add(other *LLMUsage) {
 	u.PromptTokens += other.PromptTokens
+	u.CachedPromptTokens += other.CachedPromptTokens
 	u.CompletionTokens += other.CompletionTokens
 	u.TotalTokens += other.TotalTokens
 	u.Turns += other.Turns
@@ -45,6 +106,8 @@ func (u *LLMUsage) Add(other *LLMUsage) {
 	for _, toolCall := range other.ToolCalls {
 		u.ToolCalls = append(u.ToolCalls, toolCall)
 	}
+
+	u.Breakdown = append(u.Breakdown, other.Breakdown...)
 }
 
 func (u *LLMUsage) AddToolCall(toolCall *tools.ToolCall, err error) {
@@ -55,11 +118,52 @@ func (u *LLMUsage) AddToolCall(toolCall *tools.ToolCall, err error) {
 	})
 }
 
+// AddCachedToolCall records a tool call served from a cache
+func (u *LLMUsage) AddCachedToolCall(toolCall *tools.ToolCall) {
+	u.ToolCalls = append(u.ToolCalls, &LLMUsageToolCall{
+		Name:   toolCall.Name,
+		Args:   toolCall.Args,
+		Cached: true,
+	})
+}
+
+// CachedToolCalls returns the number of tool calls served from a cache
+func (u *LLMUsage) CachedToolCalls() int {
+	cached := 0
+	for _, toolCall := range u.ToolCalls {
+		if toolCall.Cached {
+			cached++
+		}
+	}
+
+	return cached
+}
+
+// Cost returns the estimated cost in USD using the default price table
+func (u *LLMUsage) Cost() float64 {
+	return u.CostWith(DefaultPricing())
+}
+
+// CostWith returns the estimated cost in USD, pricing every call of the breakdown
+// by its model. Calls to models missing from the price table are not counted.
+func (u *LLMUsage) CostWith(pricing *Pricing) float64 {
+	var cost float64
+	for _, turn := range u.Breakdown {
+		if price, ok := pricing.Price(turn.Model); ok {
+			cost += price.Cost(turn.LLMUsageTokens)
+		}
+	}
+
+	return cost
+}
+
 func (u *LLMUsage) String() string {
-	summary := fmt.Sprintf("prompt: %d, completion: %d, total: %d, tools: %v", u.PromptTokens, u.CompletionTokens, u.TotalTokens, len(u.ToolCalls))
+	summary := fmt.Sprintf("prompt: %d, completion: %d, total: %d, tools: %v, cost: $%.4f", u.PromptTokens, u.CompletionTokens, u.TotalTokens, len(u.ToolCalls), u.Cost())
 	for _, toolCall := range u.ToolCalls {
 		if toolCall.Error != nil {
 			summary += fmt.Sprintf("\n  - [ERR] %s, %s, %s", toolCall.Error, toolCall.Name, toolCall.Args)
+		} else if toolCall.Cached {
+			summary += fmt.Sprintf("\n  - [CACHED] %s, %s", toolCall.Name, toolCall.Args)
 		} else {
 			summary += fmt.Sprintf("\n  - %s, %s", toolCall.Name, toolCall.Args)
 		}
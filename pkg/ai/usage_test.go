@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageCostMixedModels(t *testing.T) {
+	pricing := NewPricing(map[ModelId]*ModelPrice{
+		Claude4Sonnet: {Input: 3, Output: 15, CachedInput: 0.3},
+		Claude3Haiku:  {Input: 1, Output: 5},
+	})
+
+	sonnet := NewLLMUsage(1_000_000, 100_000, 1_100_000)
+	sonnet.CachedPromptTokens = 500_000
+
+	usage := &LLMUsage{}
+	usage.Add(sonnet.ForModel(Claude4Sonnet))
+	usage.Add(NewLLMUsage(200_000, 10_000, 210_000).ForModel(Claude3Haiku))
+	usage.Add(NewLLMUsage(1_000, 1_000, 2_000).ForModel("unknown"))
+
+	// sonnet: 0.5M * 3 + 0.5M * 0.3 + 0.1M * 15 = 3.15, haiku: 0.2M * 1 + 0.01M * 5 = 0.25
+	require.InDelta(t, 3.4, usage.CostWith(pricing), 1e-9)
+	require.Len(t, usage.Breakdown, 3)
+}
+
+func TestLoadPricing(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "pricing.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte("claude-4-sonnet:\n  input: 3\n  output: 15\n  cached_input: 0.3\n"), 0o644))
+
+	jsonPath := filepath.Join(dir, "pricing.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"claude-4-sonnet": {"input": 3, "output": 15, "cached_input": 0.3}}`), 0o644))
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		pricing, err := LoadPricing(path)
+		require.NoError(t, err)
+
+		price, ok := pricing.Price(Claude4Sonnet)
+		require.True(t, ok)
+		require.Equal(t, &ModelPrice{Input: 3, Output: 15, CachedInput: 0.3}, price)
+	}
+
+	_, err := LoadPricing(filepath.Join(dir, "pricing.toml"))
+	require.Error(t, err)
+}
+
+func TestPricingMerge(t *testing.T) {
+	a := NewPricing(map[ModelId]*ModelPrice{Claude4Sonnet: {Input: 3, Output: 15}})
+	b := NewPricing(map[ModelId]*ModelPrice{Claude3Haiku: {Input: 1, Output: 5}})
+
+	// Merging into itself and in opposite directions at once must not deadlock
+	a.Merge(a)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			a.Merge(b)
+		}()
+		go func() {
+			defer wg.Done()
+			b.Merge(a)
+		}()
+	}
+	wg.Wait()
+
+	for _, pricing := range []*Pricing{a, b} {
+		_, ok := pricing.Price(Claude4Sonnet)
+		require.True(t, ok)
+		_, ok = pricing.Price(Claude3Haiku)
+		require.True(t, ok)
+	}
+}
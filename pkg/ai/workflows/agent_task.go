@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
@@ -36,13 +37,16 @@ func (t *AgentTask) Clone() Task {
 }
 
 func (t *AgentTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
-	// Add hook to save the agent progress
-	hook := NewAgentStorageHook(t.Name_)
-	t.AgentOpts = append(t.AgentOpts, agent.WithEvents(hook))
+	// Add hook to save the agent progress, without modifying the task so it can be invoked concurrently.
+	// Tool calls are approved through SubmitApproval unless the task has its own approver.
+	key := checkpointKey(ctx, t.Name_, history, t.definition)
+	hook := NewAgentStorageHook(key)
+	agentOpts := append([]agent.AgentOpts{agent.WithApprover(storageApprover)}, t.AgentOpts...)
+	agentOpts = append(agentOpts, agent.WithEvents(hook))
 
 	request := t.Request.Clone(ai.WithAddedHistory(history))
 
-	if response, ok := loadAgentTask(ctx, t.Name_); ok {
+	if response, ok := loadAgentTask(ctx, key); ok {
 		if response.Terminal {
 			slog.Info("skipping", "history", len(response.Response.Messages))
 			return response.Response, nil
@@ -51,11 +55,23 @@ func (t *AgentTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History)
 
 			slog.Info("continuing", "history", len(response.Response.Messages))
 			request = t.Request.Clone(ai.WithHistory(response.Response.Messages))
+
+			// Agent suspended for approval executes the pending tool calls first
+			if len(response.Pending) > 0 {
+				agentOpts = append(agentOpts, agent.WithResume(response.Pending))
+			}
+		}
+	}
+
+	response, err := agent.NewAgent(llm, agentOpts...).Invoke(ctx, request)
+
+	var suspended *agent.ErrApprovalRequired
+	if errors.As(err, &suspended) {
+		if err := saveSuspendedAgentTask(ctx, key, suspended); err != nil {
+			return nil, err
 		}
 	}
 
-	agent := agent.NewAgent(llm, t.AgentOpts...)
-	response, err := agent.Invoke(ctx, request)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +79,10 @@ func (t *AgentTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History)
 	return response, nil
 }
 
+func (t *AgentTask) definition() any {
+	return newRequestDefinition(t.Request)
+}
+
 func (t *AgentTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
 	new := t.Clone().(*AgentTask)
 	for _, opt := range opts {
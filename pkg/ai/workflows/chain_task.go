@@ -32,48 +32,67 @@ func (c *ChainTask) Name() string {
 	return c.before.Name() + " > " + c.after.Name()
 }
 
+// Invoke runs both tasks in sequence. A chain renamed by WithName scopes checkpoints of its tasks.
 func (c *ChainTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	if c.name != "" {
+		ctx = withTaskPath(ctx, c.name)
+	}
+
 	before, err := c.before.Invoke(ctx, llm, history)
 	if err != nil {
 		return nil, err
 	}
 
+	messages := before.Messages
 	if c.lastOnly {
-		if last := before.Messages.Last(); last != nil {
-			before.Messages = ai.NewHistory(last)
+		if last := messages.Last(); last != nil {
+			messages = ai.NewHistory(last)
 		} else {
 			return nil, fmt.Errorf("last message is nil")
 		}
 	}
 
-	after, err := c.after.Invoke(ctx, llm, before.Messages)
+	after, err := c.after.Invoke(ctx, llm, messages)
 	if err != nil {
 		return nil, err
 	}
 
-	// Add usage to get a total
-	after.Usage.Add(before.Usage)
+	// Add usage to get a total, responses of the tasks may be shared, e.g. loaded from a checkpoint
+	usage := &ai.LLMUsage{}
+	for _, response := range []*ai.LLMResponse{after, before} {
+		if response.Usage != nil {
+			usage.Add(response.Usage)
+		}
+	}
+
+	return after.Clone().SetUsage(usage).MergeMetadata(before), nil
+}
 
-	return after, nil
+func (c *ChainTask) definition() any {
+	return []any{taskDefinition(c.before), taskDefinition(c.after), c.lastOnly}
 }
 
 func (c *ChainTask) Clone() Task {
 	return &ChainTask{
-		before: c.before.Clone(),
-		after:  c.after.Clone(),
+		before:   c.before.Clone(),
+		after:    c.after.Clone(),
+		lastOnly: c.lastOnly,
+		name:     c.name,
 	}
 }
 
 func (c *ChainTask) WithName(name string) Task {
-	c.name = name
-
-	return c
+	new := c.Clone().(*ChainTask)
+	new.name = name
+	return new
 }
 
 func (c *ChainTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
 	return &ChainTask{
-		before: c.before.WithRequestOpts(opts...),
-		after:  c.after.WithRequestOpts(opts...),
+		before:   c.before.WithRequestOpts(opts...),
+		after:    c.after.WithRequestOpts(opts...),
+		lastOnly: c.lastOnly,
+		name:     c.name,
 	}
 }
 
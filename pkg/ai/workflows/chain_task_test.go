@@ -63,3 +63,30 @@ func (s *ChainTaskTestSuite) TestConcat() {
 		s.Require().Equal(ai.NewSystemMessage("Three"), res.Messages[1])
 	})
 }
+
+func (s *ChainTaskTestSuite) TestCopiesKeepSettings() {
+	ctx := context.Background()
+
+	one := NewPreloadTask("one", func(ctx context.Context) (ai.History, error) {
+		return ai.History{ai.NewSystemMessage("One")}, nil
+	})
+	two := NewPreloadTask("two", func(ctx context.Context) (ai.History, error) {
+		return ai.History{ai.NewSystemMessage("Two")}, nil
+	})
+
+	chain := NewChainTask(one, two, true)
+	renamed := chain.WithName("steps")
+
+	// Renaming returns a copy, the original keeps its name
+	s.Equal("one > two", chain.Name())
+	s.Equal("steps", renamed.Name())
+
+	for _, task := range []Task{renamed.Clone(), renamed.WithRequestOpts(ai.WithSystem("Be brief."))} {
+		s.Equal("steps", task.Name())
+
+		// Only the last message of the first task is passed on
+		res, err := task.Invoke(ctx, &MockLLM{}, ai.History{ai.NewSystemMessage("Input")})
+		s.NoError(err)
+		s.Equal(ai.History{ai.NewSystemMessage("One"), ai.NewSystemMessage("Two")}, res.Messages)
+	}
+}
@@ -0,0 +1,182 @@
+package workflows
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+)
+
+// Checkpoints are keyed by the path of the task through the task tree, its name and
+// a hash of its input, e.g. "research/0/summary@3f2a9c0d1e4b5a6c". Combinators add
+// their name to the path of their children, so tasks of the same name in different
+// parts of a workflow don't overwrite each other, and a changed input runs the task again.
+
+// CheckpointMode decides which changes of a task invalidate its checkpoint
+type CheckpointMode string
+
+const (
+	// CheckpointByInput hashes the input of the task only, this is the default
+	CheckpointByInput CheckpointMode = "input"
+
+	// CheckpointByDefinition also hashes the definition of the task (model, system prompt,
+	// tools, schema), so a changed prompt runs the task again. Stale checkpoints of the
+	// task are deleted instead of silently lingering in the storage.
+	CheckpointByDefinition CheckpointMode = "definition"
+)
+
+type checkpointModeKey struct{}
+
+// WithCheckpointMode sets how checkpoints of the workflow are invalidated
+func WithCheckpointMode(ctx context.Context, mode CheckpointMode) context.Context {
+	return context.WithValue(ctx, checkpointModeKey{}, mode)
+}
+
+func checkpointModeFrom(ctx context.Context) CheckpointMode {
+	if mode, ok := ctx.Value(checkpointModeKey{}).(CheckpointMode); ok {
+		return mode
+	}
+
+	return CheckpointByInput
+}
+
+type taskPathKey struct{}
+
+// withTaskPath nests the context under the given path segments, used by combinators
+// to scope checkpoints of their children
+func withTaskPath(ctx context.Context, segments ...string) context.Context {
+	path := append(taskPath(ctx), segments...)
+	return context.WithValue(ctx, taskPathKey{}, path)
+}
+
+func taskPath(ctx context.Context) []string {
+	path, _ := ctx.Value(taskPathKey{}).([]string)
+
+	// Clip so nested paths appending to it don't share the backing array
+	return path[:len(path):len(path)]
+}
+
+// checkpointKey derives the storage key of a task from its path, name and input.
+// Definition of the task is only evaluated in CheckpointByDefinition mode.
+func checkpointKey(ctx context.Context, name string, input any, definition func() any) string {
+	fingerprint := []any{input}
+	if definition != nil && checkpointModeFrom(ctx) == CheckpointByDefinition {
+		fingerprint = append(fingerprint, definition())
+	}
+
+	base := strings.Join(append(taskPath(ctx), name), "/")
+
+	payload, err := json.Marshal(fingerprint)
+	if err != nil {
+		slog.Warn("failed to hash checkpoint input, keying by path only", "task", base, "error", err)
+		return base
+	}
+
+	hash := sha256.Sum256(payload)
+	return base + "@" + hex.EncodeToString(hash[:8])
+}
+
+// loadCheckpoint loads a stored item. In CheckpointByDefinition mode a miss deletes
+// checkpoints of the same task stored under a different hash.
+func loadCheckpoint(ctx context.Context, id string) (any, bool) {
+	storage, ok := StorageFrom(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	value, err := storage.Load(ctx, id)
+	if err != nil {
+		return nil, false
+	}
+
+	if value == nil && checkpointModeFrom(ctx) == CheckpointByDefinition {
+		invalidateStale(ctx, storage, id)
+	}
+
+	return value, value != nil
+}
+
+func invalidateStale(ctx context.Context, storage Storage, id string) {
+	base, _, found := strings.Cut(id, "@")
+	if !found {
+		return
+	}
+
+	ids, err := storage.List(ctx)
+	if err != nil {
+		slog.Warn("failed to list checkpoints", "error", err)
+		return
+	}
+
+	for _, stored := range ids {
+		if strings.HasPrefix(stored, base+"@") && stored != id {
+			slog.Info("invalidating stale checkpoint", "checkpoint", stored)
+
+			if err := storage.Delete(ctx, stored); err != nil {
+				slog.Warn("failed to delete stale checkpoint", "checkpoint", stored, "error", err)
+			}
+		}
+	}
+}
+
+// definer is implemented by tasks whose behaviour is defined by data, e.g. a prompt
+type definer interface {
+	definition() any
+}
+
+// taskDefinition describes what a task does for CheckpointByDefinition hashing,
+// tasks defined by Go callbacks have no definition
+func taskDefinition(task Task) any {
+	if definer, ok := task.(definer); ok {
+		return definer.definition()
+	}
+
+	return nil
+}
+
+type toolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type requestDefinition struct {
+	Model               ai.ModelId        `json:"model"`
+	System              string            `json:"system"`
+	History             ai.History        `json:"history,omitempty"`
+	Tools               []*toolDefinition `json:"tools,omitempty"`
+	ToolUsage           any               `json:"tool_usage,omitempty"`
+	ToolUsageType       string            `json:"tool_usage_type,omitempty"`
+	MaxCompletionTokens int               `json:"max_completion_tokens,omitempty"`
+	Temperature         float64           `json:"temperature,omitempty"`
+}
+
+func newRequestDefinition(request *ai.LLMRequest) *requestDefinition {
+	definition := &requestDefinition{
+		Model:               request.Model,
+		System:              request.System,
+		History:             request.History,
+		ToolUsage:           request.ToolUsage,
+		MaxCompletionTokens: request.MaxCompletionTokens,
+		Temperature:         request.Temperature,
+	}
+
+	// Tool usages of different types may encode the same way
+	if request.ToolUsage != nil {
+		definition.ToolUsageType = string(request.ToolUsage.Type())
+	}
+
+	for _, tool := range request.Tools {
+		definition.Tools = append(definition.Tools, &toolDefinition{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.InputSchemaRaw(),
+		})
+	}
+
+	return definition
+}
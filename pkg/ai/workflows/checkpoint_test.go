@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// checkpointPaths lists stored checkpoints without their input hashes
+func checkpointPaths(t *testing.T, storage Storage) []string {
+	ids, err := storage.List(context.Background())
+	require.NoError(t, err)
+
+	paths := make([]string, len(ids))
+	for i, id := range ids {
+		paths[i], _, _ = strings.Cut(id, "@")
+	}
+
+	return paths
+}
+
+// deleteCheckpoints removes all checkpoints under the path prefix
+func deleteCheckpoints(t *testing.T, storage Storage, prefix string) {
+	ids, err := storage.List(context.Background())
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) {
+			require.NoError(t, storage.Delete(context.Background(), id))
+		}
+	}
+}
+
+type CheckpointTestSuite struct {
+	suite.Suite
+	*require.Assertions
+
+	storage Storage
+	ctx     context.Context
+}
+
+func TestCheckpointSuite(t *testing.T) {
+	suite.Run(t, new(CheckpointTestSuite))
+}
+
+func (s *CheckpointTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+	s.storage = NewMemoryStorage("checkpoints")
+	s.ctx = WithStorage(context.Background(), s.storage)
+}
+
+func (s *CheckpointTestSuite) counting(name, answer string, calls *int) Task {
+	return NewLazyTask(name, func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		*calls++
+		return ai.NewLLMResponse(ai.NewAssistantMessage(answer)), nil
+	})
+}
+
+func (s *CheckpointTestSuite) TestSameNameInDifferentBranches() {
+	var calls int
+
+	task := NewParallelTask("research", []Task{
+		s.counting("summary", "Orders", &calls).WithName("summary"),
+		s.counting("summary", "Customers", &calls).WithName("summary"),
+	})
+
+	res, err := task.Invoke(s.ctx, &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Summarise")))
+	s.NoError(err)
+	s.Equal([]string{"research/0/summary", "research/1/summary"}, checkpointPaths(s.T(), s.storage))
+
+	// Resumed run restores both branches rather than one overwriting the other
+	resumed, err := task.Invoke(s.ctx, &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Summarise")))
+	s.NoError(err)
+	s.Equal(2, calls)
+	s.Equal(res.Messages, resumed.Messages)
+}
+
+func (s *CheckpointTestSuite) TestRenamedChainScopesChildren() {
+	var calls int
+	chain := s.counting("fetch", "Rows", &calls).Then(s.counting("describe", "Table", &calls))
+
+	_, err := chain.WithName("orders").Invoke(s.ctx, &MockLLM{}, ai.History{})
+	s.NoError(err)
+
+	_, err = chain.WithName("customers").Invoke(s.ctx, &MockLLM{}, ai.History{})
+	s.NoError(err)
+
+	s.Equal(4, calls)
+	s.Equal([]string{
+		"customers/describe", "customers/fetch",
+		"orders/describe", "orders/fetch",
+	}, checkpointPaths(s.T(), s.storage))
+}
+
+func (s *CheckpointTestSuite) TestChangedInputRunsAgain() {
+	var calls int
+	task := s.counting("answer", "42", &calls)
+
+	for _, question := range []string{"What is the answer?", "What is the answer?", "What is the question?"} {
+		_, err := task.Invoke(s.ctx, &MockLLM{}, ai.NewHistory(ai.NewUserMessage(question)))
+		s.NoError(err)
+	}
+
+	s.Equal(2, calls)
+}
+
+func (s *CheckpointTestSuite) TestChangedPrompt() {
+	mockLLM := &MockLLM{}
+	mockLLM.On("Invoke", mock.Anything, mock.Anything).Return(ai.NewLLMResponse(ai.NewAssistantMessage("Done")), nil)
+
+	history := ai.NewHistory(ai.NewUserMessage("Summarise the incident"))
+	invoke := func(ctx context.Context, system string) {
+		task := NewTask("summary", ai.NewLLMRequest(ai.WithModel(ai.Claude4Sonnet), ai.WithSystem(system)))
+
+		_, err := task.Invoke(ctx, mockLLM, history)
+		s.NoError(err)
+	}
+
+	// Input only checkpoints ignore the changed prompt
+	invoke(s.ctx, "Be brief.")
+	invoke(s.ctx, "Be very brief.")
+	mockLLM.AssertNumberOfCalls(s.T(), "Invoke", 1)
+
+	// Definition checkpoints run the task again and drop the stale checkpoint
+	ctx := WithCheckpointMode(s.ctx, CheckpointByDefinition)
+	invoke(ctx, "Be brief.")
+	invoke(ctx, "Be brief.")
+	invoke(ctx, "Be very brief.")
+	mockLLM.AssertNumberOfCalls(s.T(), "Invoke", 3)
+
+	s.Equal([]string{"summary"}, checkpointPaths(s.T(), s.storage))
+}
@@ -0,0 +1,611 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/prompts"
+)
+
+// DAG runs Work and Task nodes as a graph of data dependencies. Nodes whose
+// dependencies finished run concurrently, every node is checkpointed, so
+// a resumed run only invokes the nodes which didn't finish.
+//
+//	dag := NewDAG("tables")
+//	AddWork(dag, "schema", fetchSchema, DAGInput)
+//	AddWork(dag, "stats", fetchStats, DAGInput)
+//	dag.AddTask("summary", summarize, "schema", "stats")
+//
+// DAG implements Task, its response is the output of the output node.
+
+// DAGInput is the implicit root of every DAG, its output is the *ai.History the DAG is invoked with
+const DAGInput = "input"
+
+type dagNodeKind string
+
+const (
+	dagNodeInput dagNodeKind = "input"
+	dagNodeWork  dagNodeKind = "work"
+	dagNodeTask  dagNodeKind = "task"
+	dagNodeJoin  dagNodeKind = "join"
+)
+
+// dagRunFunc runs a node with outputs of its dependencies, in the order of the dependencies
+type dagRunFunc func(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (any, *ai.LLMUsage, error)
+
+type dagNode struct {
+	name string
+	kind dagNodeKind
+	deps []string
+
+	// expected output types of the dependencies, nil accepts any output
+	inputs []reflect.Type
+	output reflect.Type
+
+	// task of task nodes, kept to support WithRequestOpts
+	task Task
+
+	run dagRunFunc
+}
+
+type DAG struct {
+	name   string
+	nodes  map[string]*dagNode
+	order  []string
+	output string
+	errs   []error
+}
+
+func NewDAG(name string) *DAG {
+	d := &DAG{name: name, nodes: map[string]*dagNode{}}
+	d.add(&dagNode{name: DAGInput, kind: dagNodeInput, output: reflect.TypeFor[*ai.History]()})
+
+	return d
+}
+
+func (d *DAG) add(node *dagNode) {
+	if _, ok := d.nodes[node.name]; ok {
+		d.errs = append(d.errs, fmt.Errorf("duplicate node %q", node.name))
+		return
+	}
+
+	d.nodes[node.name] = node
+	d.order = append(d.order, node.name)
+}
+
+// AddWork adds a node invoking work with the output of the from node, which must be *I.
+// Use DAGInput as from to work with the history the DAG is invoked with. The node isn't
+// checkpointed by the DAG, works checkpoint themselves, see FunctionWork.
+func AddWork[I any, O any](d *DAG, name string, work Work[I, O], from string) *DAG {
+	d.add(&dagNode{
+		name:   name,
+		kind:   dagNodeWork,
+		deps:   []string{from},
+		inputs: []reflect.Type{reflect.TypeFor[*I]()},
+		output: reflect.TypeFor[*O](),
+		run: func(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (any, *ai.LLMUsage, error) {
+			out, err := work.Invoke(withTaskPath(ctx, name), llm, inputs[0].(*I))
+			return out, nil, err
+		},
+	})
+
+	return d
+}
+
+// AddTask adds a node invoking the task with the history the DAG is invoked with, followed by
+// outputs of the dependencies. Messages of task dependencies are appended as they are,
+// outputs of other nodes as a user message with a JSON block titled by the node name.
+// The output of the node is the *ai.LLMResponse of the task.
+func (d *DAG) AddTask(name string, task Task, deps ...string) *DAG {
+	d.add(&dagNode{
+		name:   name,
+		kind:   dagNodeTask,
+		deps:   deps,
+		inputs: make([]reflect.Type, len(deps)),
+		output: reflect.TypeFor[*ai.LLMResponse](),
+		task:   task,
+		run: func(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (any, *ai.LLMUsage, error) {
+			response, usage, err := invokeDAGTask(ctx, llm, node, history, inputs)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return response, usage, nil
+		},
+	})
+
+	return d
+}
+
+// AddTypedTask adds a task node like AddTask, its output is the parsed *T of the typed task
+func AddTypedTask[T any](d *DAG, name string, task *typed[T], deps ...string) *DAG {
+	d.add(&dagNode{
+		name:   name,
+		kind:   dagNodeTask,
+		deps:   deps,
+		inputs: make([]reflect.Type, len(deps)),
+		output: reflect.TypeFor[*T](),
+		task:   task,
+		run: func(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (any, *ai.LLMUsage, error) {
+			response, usage, err := invokeDAGTask(ctx, llm, node, history, inputs)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			lastMessage := response.LastMessageAsText()
+			if lastMessage == nil {
+				return nil, nil, fmt.Errorf("last message is not a text message")
+			}
+
+			out := new(T)
+			if err := json.Unmarshal([]byte(lastMessage.Content), out); err != nil {
+				return nil, nil, err
+			}
+
+			return out, usage, nil
+		},
+	})
+
+	return d
+}
+
+// DAGInputs are outputs of the dependencies of a join node, keyed by node name
+type DAGInputs map[string]any
+
+// InputOf returns the output of the named dependency
+func InputOf[T any](inputs DAGInputs, name string) (*T, error) {
+	value, ok := inputs[name].(*T)
+	if !ok {
+		return nil, fmt.Errorf("input %q is %T, not %T", name, inputs[name], value)
+	}
+
+	return value, nil
+}
+
+// JoinFunc combines outputs of several nodes into a single value
+type JoinFunc[O any] func(ctx context.Context, llm ai.LLM, inputs DAGInputs) (*O, error)
+
+// AddJoin adds a node combining outputs of the dependencies with a Go function
+func AddJoin[O any](d *DAG, name string, deps []string, join JoinFunc[O]) *DAG {
+	d.add(&dagNode{
+		name:   name,
+		kind:   dagNodeJoin,
+		deps:   deps,
+		inputs: make([]reflect.Type, len(deps)),
+		output: reflect.TypeFor[*O](),
+		run: func(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (any, *ai.LLMUsage, error) {
+			named := make(DAGInputs, len(inputs))
+			for i, dep := range node.deps {
+				named[dep] = inputs[i]
+			}
+
+			key := checkpointKey(ctx, name, named, nil)
+			if out, ok := loadWork[O](ctx, key); ok {
+				return out, nil, nil
+			}
+
+			out, err := join(withTaskPath(ctx, name), llm, named)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			out, err = saveWork(ctx, key, out)
+			return out, nil, err
+		},
+	})
+
+	return d
+}
+
+// invokeDAGTask builds the history of a task node from its dependencies and invokes the task,
+// checkpointing the response. It returns usage of the invocation, none for a resumed response.
+func invokeDAGTask(ctx context.Context, llm ai.LLM, node *dagNode, history ai.History, inputs []any) (*ai.LLMResponse, *ai.LLMUsage, error) {
+	input := slices.Clip(history)
+
+	for i, dep := range node.deps {
+		switch value := inputs[i].(type) {
+		case *ai.History:
+			// The DAG input is already part of the history
+
+		case *ai.LLMResponse:
+			input = input.Append(withoutPrefix(value.Messages, history)...)
+
+		default:
+			payload, err := json.MarshalIndent(value, "", "  ")
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to marshal output of %s: %w", dep, err)
+			}
+
+			input = input.Append(prompts.NewPromptBuilder().
+				AddBlock(fmt.Sprintf("```json\n%s\n```", payload), prompts.WithTitle(dep)).
+				BuildUserMessage())
+		}
+	}
+
+	key := checkpointKey(ctx, node.name, input, func() any { return taskDefinition(node.task) })
+	if response, ok := loadTask(ctx, key); ok {
+		return response, nil, nil
+	}
+
+	response, err := node.task.Invoke(withTaskPath(ctx, node.name), llm, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err = saveTask(ctx, key, response)
+	return response, response.Usage, err
+}
+
+// Output sets the node whose output is the response of the DAG invoked as a Task.
+// Defaults to the only node nothing depends on.
+func (d *DAG) Output(name string) *DAG {
+	d.output = name
+	return d
+}
+
+// Validate checks the graph for duplicate and missing nodes, mismatching types and cycles
+func (d *DAG) Validate() error {
+	errs := slices.Clone(d.errs)
+
+	for _, name := range d.order {
+		node := d.nodes[name]
+
+		for i, dep := range node.deps {
+			source, ok := d.nodes[dep]
+			if !ok {
+				errs = append(errs, fmt.Errorf("node %q depends on missing node %q", name, dep))
+				continue
+			}
+
+			if expected := node.inputs[i]; expected != nil && expected != source.output {
+				errs = append(errs, fmt.Errorf("node %q expects %s from %q, which outputs %s", name, expected, dep, source.output))
+			}
+		}
+	}
+
+	if d.output != "" {
+		if _, ok := d.nodes[d.output]; !ok {
+			errs = append(errs, fmt.Errorf("output node %q doesn't exist", d.output))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid dag %s: %w", d.name, errors.Join(errs...))
+	}
+
+	if cycle := d.findCycle(); cycle != nil {
+		return fmt.Errorf("invalid dag %s: cycle %s", d.name, strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// findCycle returns the nodes of a dependency cycle, or nil if the graph is acyclic
+func (d *DAG) findCycle() []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := map[string]int{}
+	var stack []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(stack, name)
+			return append(slices.Clone(stack[start:]), name)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, dep := range d.nodes[name].deps {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, name := range d.order {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// outputNode is the explicitly set output, or the only node without dependents
+func (d *DAG) outputNode() (string, error) {
+	if d.output != "" {
+		return d.output, nil
+	}
+
+	dependents := map[string]bool{}
+	for _, node := range d.nodes {
+		for _, dep := range node.deps {
+			dependents[dep] = true
+		}
+	}
+
+	var sinks []string
+	for _, name := range d.order {
+		if name != DAGInput && !dependents[name] {
+			sinks = append(sinks, name)
+		}
+	}
+
+	if len(sinks) != 1 {
+		return "", fmt.Errorf("dag %s has %d nodes without dependents, set one with Output", d.name, len(sinks))
+	}
+
+	return sinks[0], nil
+}
+
+// DAGResult holds outputs of all nodes of a run
+type DAGResult struct {
+	outputs map[string]any
+
+	// Usage summed over task nodes invoked by the run, nodes resumed
+	// from checkpoints add nothing whatever their kind
+	Usage *ai.LLMUsage
+}
+
+// ResultOf returns the output of the named node
+func ResultOf[T any](result *DAGResult, node string) (*T, error) {
+	return InputOf[T](result.outputs, node)
+}
+
+// Run invokes all nodes of the graph and returns their outputs
+func (d *DAG) Run(ctx context.Context, llm ai.LLM, history ai.History) (*DAGResult, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx = withTaskPath(ctx, d.name)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failed   error
+	)
+
+	result := &DAGResult{
+		outputs: map[string]any{DAGInput: &history},
+		Usage:   &ai.LLMUsage{},
+	}
+
+	done := make(map[string]chan struct{}, len(d.nodes))
+	for name := range d.nodes {
+		done[name] = make(chan struct{})
+	}
+	close(done[DAGInput])
+
+	for _, name := range d.order[1:] {
+		node := d.nodes[name]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Failed nodes never finish, their dependents stop on the cancellation
+			for _, dep := range node.deps {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			mu.Lock()
+			inputs := make([]any, len(node.deps))
+			for i, dep := range node.deps {
+				inputs[i] = result.outputs[dep]
+			}
+			mu.Unlock()
+
+			output, usage, err := node.run(ctx, llm, node, history, inputs)
+			if err != nil {
+				failOnce.Do(func() {
+					failed = fmt.Errorf("dag %s node %s: %w", d.name, node.name, err)
+					cancel()
+				})
+				return
+			}
+
+			mu.Lock()
+			result.outputs[node.name] = output
+			if usage != nil {
+				result.Usage.Add(usage)
+			}
+			mu.Unlock()
+
+			close(done[node.name])
+		}()
+	}
+
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (d *DAG) Name() string {
+	return d.name
+}
+
+// Invoke runs the graph and responds with the output node. Output of a task node is returned
+// after the input history, other outputs as an assistant message with their JSON.
+func (d *DAG) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	output, err := d.outputNode()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.Run(ctx, llm, history)
+	if err != nil {
+		return nil, err
+	}
+
+	response := ai.NewLLMResponse().SetUsage(result.Usage)
+
+	switch value := result.outputs[output].(type) {
+	case *ai.LLMResponse:
+		response.Messages = history.Append(withoutPrefix(value.Messages, history)...)
+		response.MergeMetadata(value)
+
+	default:
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal output of %s: %w", output, err)
+		}
+
+		response.Messages = history.Append(ai.NewAssistantMessage(string(payload)))
+	}
+
+	return response, nil
+}
+
+// DOT renders the graph in the Graphviz DOT language
+func (d *DAG) DOT() string {
+	shapes := map[dagNodeKind]string{
+		dagNodeInput: "oval",
+		dagNodeWork:  "box",
+		dagNodeTask:  "box, style=rounded",
+		dagNodeJoin:  "diamond",
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", d.name)
+	b.WriteString("  rankdir=LR;\n")
+
+	for _, name := range d.order {
+		fmt.Fprintf(&b, "  %q [shape=%s];\n", name, shapes[d.nodes[name].kind])
+	}
+
+	for _, name := range d.order {
+		for _, dep := range d.nodes[name].deps {
+			fmt.Fprintf(&b, "  %q -> %q;\n", dep, name)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (d *DAG) Mermaid() string {
+	shapes := map[dagNodeKind][2]string{
+		dagNodeInput: {"([", "])"},
+		dagNodeWork:  {"[[", "]]"},
+		dagNodeTask:  {"(", ")"},
+		dagNodeJoin:  {"{", "}"},
+	}
+
+	ids := make(map[string]string, len(d.order))
+	for i, name := range d.order {
+		ids[name] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for _, name := range d.order {
+		shape := shapes[d.nodes[name].kind]
+		fmt.Fprintf(&b, "  %s%s%q%s\n", ids[name], shape[0], name, shape[1])
+	}
+
+	for _, name := range d.order {
+		for _, dep := range d.nodes[name].deps {
+			if id, ok := ids[dep]; ok {
+				fmt.Fprintf(&b, "  %s --> %s\n", id, ids[name])
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func (d *DAG) definition() any {
+	definitions := make(map[string]any, len(d.nodes))
+	for name, node := range d.nodes {
+		if node.task != nil {
+			definitions[name] = []any{node.deps, taskDefinition(node.task)}
+		} else {
+			definitions[name] = node.deps
+		}
+	}
+
+	return definitions
+}
+
+func (d *DAG) Clone() Task {
+	clone := &DAG{
+		name:   d.name,
+		nodes:  make(map[string]*dagNode, len(d.nodes)),
+		order:  slices.Clone(d.order),
+		output: d.output,
+		errs:   slices.Clone(d.errs),
+	}
+
+	for name, node := range d.nodes {
+		copied := *node
+		if node.task != nil {
+			copied.task = node.task.Clone()
+		}
+
+		clone.nodes[name] = &copied
+	}
+
+	return clone
+}
+
+func (d *DAG) WithName(name string) Task {
+	new := d.Clone().(*DAG)
+	new.name = name
+	return new
+}
+
+func (d *DAG) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
+	new := d.Clone().(*DAG)
+	for _, node := range new.nodes {
+		if node.task != nil {
+			node.task = node.task.WithRequestOpts(opts...)
+		}
+	}
+
+	return new
+}
+
+func (d *DAG) Then(task Task) Task {
+	return NewChainTask(d, task, false)
+}
+
+func (d *DAG) Pipe(task Task) Task {
+	return NewChainTask(d, task, true)
+}
@@ -0,0 +1,261 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DAGTestSuite struct {
+	suite.Suite
+	*require.Assertions
+}
+
+func TestDAGSuite(t *testing.T) {
+	suite.Run(t, new(DAGTestSuite))
+}
+
+func (s *DAGTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+type tableStats struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+type tableReport struct {
+	Tables    []string `json:"tables"`
+	TotalRows int      `json:"total_rows"`
+}
+
+func question(history *ai.History) string {
+	return history.Last().(*ai.TextMessage).Content
+}
+
+// statsWork reports stats of a table, waiting on started until the other branch runs as well
+func statsWork(table string, rows int, started chan struct{}, calls *atomic.Int32) Work[ai.History, tableStats] {
+	return NewFunctionWork(table, func(ctx context.Context, llm ai.LLM, in *ai.History) (*tableStats, error) {
+		calls.Add(1)
+
+		if started != nil {
+			started <- struct{}{}
+			<-started
+		}
+
+		return &tableStats{Table: table, Rows: rows}, nil
+	})
+}
+
+func reportJoin(ctx context.Context, llm ai.LLM, inputs DAGInputs) (*tableReport, error) {
+	report := &tableReport{}
+	for _, name := range []string{"orders", "customers"} {
+		stats, err := InputOf[tableStats](inputs, name)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Tables = append(report.Tables, stats.Table)
+		report.TotalRows += stats.Rows
+	}
+
+	return report, nil
+}
+
+func (s *DAGTestSuite) TestIndependentNodesRunConcurrently() {
+	var calls atomic.Int32
+
+	// Both branches block until the other one started, a sequential run would deadlock
+	started := make(chan struct{})
+	dag := NewDAG("tables")
+	AddWork(dag, "orders", statsWork("orders", 10, started, &calls), DAGInput)
+	AddWork(dag, "customers", NewFunctionWork("customers", func(ctx context.Context, llm ai.LLM, in *ai.History) (*tableStats, error) {
+		calls.Add(1)
+		<-started
+		started <- struct{}{}
+		return &tableStats{Table: "customers", Rows: 5}, nil
+	}), DAGInput)
+	AddJoin(dag, "report", []string{"orders", "customers"}, reportJoin)
+
+	res, err := dag.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Report")))
+	s.NoError(err)
+	s.Equal(int32(2), calls.Load())
+	s.JSONEq(`{"tables": ["orders", "customers"], "total_rows": 15}`, res.LastMessageAsText().Content)
+}
+
+func (s *DAGTestSuite) TestTaskNodes() {
+	var calls atomic.Int32
+
+	mockLLM := &MockLLM{}
+	mockLLM.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewToolCallMessage(tools.NewToolCall("1", "formatter", json.RawMessage(`{"table": "orders", "rows": 10}`)))), nil).
+		Once()
+	mockLLM.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Orders has 10 rows, customers 5.")).SetUsage(&ai.LLMUsage{
+			LLMUsageTokens: ai.LLMUsageTokens{PromptTokens: 100},
+		}), nil).
+		Once()
+
+	dag := NewDAG("tables")
+	AddTypedTask(dag, "orders", NewTypedTask[tableStats]("orders", ai.NewLLMRequest(ai.WithSystem("Count orders."))), DAGInput)
+	AddWork(dag, "customers", statsWork("customers", 5, nil, &calls), DAGInput)
+	dag.AddTask("summary", NewTask("summary", ai.NewLLMRequest(ai.WithSystem("Summarise the stats."))), "orders", "customers")
+
+	history := ai.NewHistory(ai.NewUserMessage("How big are the tables?"))
+	res, err := dag.Invoke(context.Background(), mockLLM, history)
+	s.NoError(err)
+	mockLLM.AssertExpectations(s.T())
+
+	// Summary sees the input followed by outputs of its dependencies
+	summaryRequest := mockLLM.Calls[1].Arguments.Get(1).(*ai.LLMRequest)
+	s.Len(summaryRequest.History, 3)
+	s.Equal(history[0], summaryRequest.History[0])
+	s.Contains(summaryRequest.History[1].(*ai.TextMessage).Content, `"rows": 10`)
+	s.Contains(summaryRequest.History[2].(*ai.TextMessage).Content, "# customers")
+
+	s.Equal(ai.NewHistory(history[0], ai.NewAssistantMessage("Orders has 10 rows, customers 5.")), res.Messages)
+	s.Equal(int64(100), res.Usage.PromptTokens)
+}
+
+func (s *DAGTestSuite) TestResume() {
+	ctx := WithStorage(context.Background(), NewMemoryStorage("tables-1"))
+
+	var calls atomic.Int32
+	fail := true
+
+	dag := NewDAG("tables")
+	AddWork(dag, "orders", statsWork("orders", 10, nil, &calls), DAGInput)
+	AddWork(dag, "customers", statsWork("customers", 5, nil, &calls), DAGInput)
+	AddJoin(dag, "report", []string{"orders", "customers"}, func(ctx context.Context, llm ai.LLM, inputs DAGInputs) (*tableReport, error) {
+		if fail {
+			return nil, errors.New("warehouse unavailable")
+		}
+		return reportJoin(ctx, llm, inputs)
+	})
+
+	history := ai.NewHistory(ai.NewUserMessage("Report"))
+
+	_, err := dag.Run(ctx, &MockLLM{}, history)
+	s.EqualError(err, "dag tables node report: warehouse unavailable")
+	s.Equal(int32(2), calls.Load())
+
+	// Resumed run reuses checkpoints of the finished nodes
+	fail = false
+	result, err := dag.Run(ctx, &MockLLM{}, history)
+	s.NoError(err)
+	s.Equal(int32(2), calls.Load())
+
+	report, err := ResultOf[tableReport](result, "report")
+	s.NoError(err)
+	s.Equal(15, report.TotalRows)
+}
+
+func (s *DAGTestSuite) TestResumeCheckpointsOnce() {
+	storage := NewMemoryStorage("tables-1")
+	ctx := WithStorage(context.Background(), storage)
+
+	mockLLM := &MockLLM{}
+	mockLLM.
+		On("Invoke", mock.Anything, mock.Anything).
+		Return(ai.NewLLMResponse(ai.NewAssistantMessage("Orders has 10 rows.")).SetUsage(&ai.LLMUsage{
+			LLMUsageTokens: ai.LLMUsageTokens{PromptTokens: 100},
+		}), nil).
+		Once()
+
+	var calls atomic.Int32
+	dag := NewDAG("tables")
+	AddWork(dag, "orders", statsWork("orders", 10, nil, &calls), DAGInput)
+	dag.AddTask("summary", NewTask("summary", ai.NewLLMRequest()), "orders")
+
+	history := ai.NewHistory(ai.NewUserMessage("How big are the tables?"))
+
+	result, err := dag.Run(ctx, mockLLM, history)
+	s.NoError(err)
+	s.Equal(int64(100), result.Usage.PromptTokens)
+
+	// The work checkpoints itself, the DAG doesn't checkpoint it again. Task responses are
+	// checkpointed by the DAG like other combinators do, e.g. MapTask.
+	s.Equal([]string{"tables/orders/orders", "tables/summary/summary", "tables/summary"}, checkpointPaths(s.T(), storage))
+
+	// Resumed nodes add no usage, whatever their kind
+	result, err = dag.Run(ctx, mockLLM, history)
+	s.NoError(err)
+	s.Equal(int32(1), calls.Load())
+	s.Equal(int64(0), result.Usage.PromptTokens)
+	mockLLM.AssertExpectations(s.T())
+}
+
+func (s *DAGTestSuite) TestValidate() {
+	var calls atomic.Int32
+
+	dag := NewDAG("invalid")
+	AddWork(dag, "orders", statsWork("orders", 10, nil, &calls), DAGInput)
+	AddWork(dag, "orders", statsWork("orders", 10, nil, &calls), DAGInput)
+	AddWork(dag, "nested", statsWork("nested", 1, nil, &calls), "orders")
+	dag.AddTask("summary", NewTask("summary", ai.NewLLMRequest()), "missing")
+
+	err := dag.Validate()
+	s.ErrorContains(err, `duplicate node "orders"`)
+	s.ErrorContains(err, `node "nested" expects *ai.History from "orders", which outputs *workflows.tableStats`)
+	s.ErrorContains(err, `node "summary" depends on missing node "missing"`)
+
+	cyclic := NewDAG("cyclic")
+	cyclic.AddTask("a", NewTask("a", ai.NewLLMRequest()), "c")
+	cyclic.AddTask("b", NewTask("b", ai.NewLLMRequest()), "a")
+	cyclic.AddTask("c", NewTask("c", ai.NewLLMRequest()), "b")
+
+	s.EqualError(cyclic.Validate(), "invalid dag cyclic: cycle a -> c -> b -> a")
+}
+
+func (s *DAGTestSuite) TestExport() {
+	var calls atomic.Int32
+
+	dag := NewDAG("tables")
+	AddWork(dag, "orders", statsWork("orders", 10, nil, &calls), DAGInput)
+	AddWork(dag, "customers", statsWork("customers", 5, nil, &calls), DAGInput)
+	AddJoin(dag, "report", []string{"orders", "customers"}, reportJoin)
+	dag.AddTask("summary", NewTask("summary", ai.NewLLMRequest()), "report")
+
+	s.Equal(strings.Join([]string{
+		`digraph "tables" {`,
+		`  rankdir=LR;`,
+		`  "input" [shape=oval];`,
+		`  "orders" [shape=box];`,
+		`  "customers" [shape=box];`,
+		`  "report" [shape=diamond];`,
+		`  "summary" [shape=box, style=rounded];`,
+		`  "input" -> "orders";`,
+		`  "input" -> "customers";`,
+		`  "orders" -> "report";`,
+		`  "customers" -> "report";`,
+		`  "report" -> "summary";`,
+		`}`,
+		``,
+	}, "\n"), dag.DOT())
+
+	s.Equal(strings.Join([]string{
+		`flowchart LR`,
+		`  n0(["input"])`,
+		`  n1[["orders"]]`,
+		`  n2[["customers"]]`,
+		`  n3{"report"}`,
+		`  n4("summary")`,
+		`  n0 --> n1`,
+		`  n0 --> n2`,
+		`  n1 --> n3`,
+		`  n2 --> n3`,
+		`  n3 --> n4`,
+		``,
+	}, "\n"), dag.Mermaid())
+}
@@ -0,0 +1,164 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileStorageProvider keeps every workflow in its own directory under root,
+// with one JSON file per stored item
+type FileStorageProvider struct {
+	root string
+}
+
+func NewFileStorageProvider(root string) *FileStorageProvider {
+	return &FileStorageProvider{root: root}
+}
+
+// Storage of an empty workflow id fails every operation, it would be the root itself
+func (p *FileStorageProvider) Storage(ctx context.Context, workflowId string) Storage {
+	if workflowId == "" {
+		return &FileStorage{err: errors.New("workflow id must not be empty")}
+	}
+
+	return NewFileStorage(filepath.Join(p.root, escapeId(workflowId)))
+}
+
+// FileStorage stores items as JSON files in a single directory. Loaded items are
+// returned as json.RawMessage and decoded by the tasks reading them.
+type FileStorage struct {
+	dir string
+
+	// err fails every operation, set for invalid workflow ids
+	err error
+}
+
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{dir: dir}
+}
+
+func (s *FileStorage) Store(ctx context.Context, itemId string, data any) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	payload, err := encodeStored(data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return errors.Wrap(err, "failed to create storage directory")
+	}
+
+	// Write to a temporary file first so a crash never leaves a half written item behind
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to write item %s", itemId)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write item %s", itemId)
+	}
+
+	return errors.Wrapf(os.Rename(tmp.Name(), s.path(itemId)), "failed to store item %s", itemId)
+}
+
+func (s *FileStorage) Load(ctx context.Context, itemId string) (any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	payload, err := os.ReadFile(s.path(itemId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load item %s", itemId)
+	}
+
+	return json.RawMessage(payload), nil
+}
+
+func (s *FileStorage) Delete(ctx context.Context, itemId string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	err := os.Remove(s.path(itemId))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete item %s", itemId)
+	}
+
+	return nil
+}
+
+func (s *FileStorage) List(ctx context.Context) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list items")
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		id, err := url.PathUnescape(name)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func (s *FileStorage) Clear(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return errors.Wrap(os.RemoveAll(s.dir), "failed to clear storage")
+}
+
+func (s *FileStorage) path(itemId string) string {
+	return filepath.Join(s.dir, escapeId(itemId)+".json")
+}
+
+// escapeId turns an id into a file name. A leading dot is escaped as well, so ids
+// like . and .. never resolve to the directory itself or its parent.
+func escapeId(id string) string {
+	escaped := url.PathEscape(id)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+
+	return escaped
+}
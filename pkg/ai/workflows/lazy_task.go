@@ -40,7 +40,9 @@ func (t *LazyTask) Clone() Task {
 }
 
 func (t *LazyTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
-	if response, ok := loadTask(ctx, t.id); ok {
+	key := checkpointKey(ctx, t.id, history, nil)
+
+	if response, ok := loadTask(ctx, key); ok {
 		return response, nil
 	}
 
@@ -52,7 +54,7 @@ func (t *LazyTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (
 
 	response.Messages = history.Append(response.Messages...)
 
-	return saveTask(ctx, t.id, response)
+	return saveTask(ctx, key, response)
 }
 
 func (t *LazyTask) WithName(name string) Task {
@@ -0,0 +1,227 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/eval/expectations"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/structured"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+)
+
+// LoopTask re-invokes its body with the accumulated history until the stop
+// condition is met or the maximum number of iterations is reached. It is meant
+// for draft, review and revise workflows.
+//
+// Checkpoints of an iteration are keyed by its path, e.g. the body at
+// "revise/0/draft@<hash>" and the verdict at "revise/0/verdict@<hash>", hashing the
+// input history. A resumed workflow skips the iterations that already finished.
+
+// LoopVerdict is the outcome of a stop condition. Feedback of a verdict which
+// doesn't stop the loop is passed to the next iteration as a user message.
+type LoopVerdict struct {
+	Stop     bool   `json:"stop"`
+	Feedback string `json:"feedback,omitempty"`
+
+	// Usage of evaluating the condition, added to the loop response
+	Usage *ai.LLMUsage `json:"usage,omitempty"`
+}
+
+// LoopCondition inspects the accumulated history after every iteration
+type LoopCondition func(ctx context.Context, llm ai.LLM, history ai.History) (*LoopVerdict, error)
+
+// UntilPredicate stops the loop once the predicate holds for the history
+func UntilPredicate(predicate func(history ai.History) bool) LoopCondition {
+	return func(ctx context.Context, llm ai.LLM, history ai.History) (*LoopVerdict, error) {
+		return &LoopVerdict{Stop: predicate(history)}, nil
+	}
+}
+
+// UntilJudged asks the LLM to score the last message of every iteration, stopping once the
+// score reaches the threshold. The request carries the judging instructions, the reason of
+// a low score is fed back to the next iteration. The judge answers with an
+// expectations.ScoringJudgeVerdict.
+func UntilJudged(request *ai.LLMRequest, threshold int, opts ...structured.LLMOpts) LoopCondition {
+	schema := tools.DefaultSchemaGenerator.MustGenerate(new(expectations.ScoringJudgeVerdict))
+
+	return func(ctx context.Context, llm ai.LLM, history ai.History) (*LoopVerdict, error) {
+		var draft string
+		for i := len(history) - 1; i >= 0; i-- {
+			if text, ok := history[i].(*ai.TextMessage); ok && text.Role() == ai.MessageRoleAssistant {
+				draft = text.Content
+				break
+			}
+		}
+		if draft == "" {
+			return nil, fmt.Errorf("no assistant text to judge")
+		}
+
+		response, err := structured.NewLLM(schema, llm, opts...).Invoke(ctx, request.Clone(
+			ai.WithAddedHistory(ai.NewHistory(ai.NewUserMessage(draft))),
+		))
+		if err != nil {
+			return nil, err
+		}
+
+		lastMessage := response.LastMessageAsText()
+		if lastMessage == nil {
+			return nil, fmt.Errorf("last message is not a text message")
+		}
+
+		var verdict expectations.ScoringJudgeVerdict
+		if err := json.Unmarshal([]byte(lastMessage.Content), &verdict); err != nil {
+			return nil, fmt.Errorf("failed to parse judge verdict: %w", err)
+		}
+
+		if verdict.Score >= threshold {
+			return &LoopVerdict{Stop: true, Usage: response.Usage}, nil
+		}
+
+		return &LoopVerdict{
+			Feedback: fmt.Sprintf("Review scored %d/100, revise the draft.\n\n%s", verdict.Score, verdict.Reason),
+			Usage:    response.Usage,
+		}, nil
+	}
+}
+
+// LoopResult is recorded in the response metadata of a LoopTask, see LoopResultFrom
+type LoopResult struct {
+	Iterations int  `json:"iterations"`
+	Stopped    bool `json:"stopped"`
+}
+
+type LoopTask struct {
+	name          string
+	body          Task
+	condition     LoopCondition
+	maxIterations int
+}
+
+// NewLoopTask creates a loop running body at most maxIterations times. When the cap is
+// reached the last iteration is returned, LoopResult tells whether the condition was met.
+// A maxIterations below 1 is treated as 1, so the body always runs.
+func NewLoopTask(name string, body Task, condition LoopCondition, maxIterations int) *LoopTask {
+	maxIterations = max(maxIterations, 1)
+
+	return &LoopTask{
+		name:          name,
+		body:          body,
+		condition:     condition,
+		maxIterations: maxIterations,
+	}
+}
+
+func (t *LoopTask) Name() string {
+	return t.name
+}
+
+func (t *LoopTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	response := ai.NewLLMResponse(history...).SetUsage(&ai.LLMUsage{})
+	result := &LoopResult{}
+
+	for iteration := 0; iteration < t.maxIterations && !result.Stopped; iteration++ {
+		iterationCtx := withTaskPath(ctx, t.name, strconv.Itoa(iteration))
+
+		output, err := t.body.Invoke(iterationCtx, llm, response.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("loop %s iteration %d: %w", t.name, iteration, err)
+		}
+
+		response.Messages = response.Messages.Append(withoutPrefix(output.Messages, response.Messages)...)
+		response.AddUsage(output.Usage)
+		response.MergeMetadata(output)
+		result.Iterations = iteration + 1
+
+		verdict, err := t.verdict(iterationCtx, llm, response.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("loop %s iteration %d: %w", t.name, iteration, err)
+		}
+
+		if verdict.Usage != nil {
+			response.AddUsage(verdict.Usage)
+		}
+		result.Stopped = verdict.Stop
+
+		if !verdict.Stop && verdict.Feedback != "" {
+			response.Messages = response.Messages.Append(ai.NewUserMessage(verdict.Feedback))
+		}
+	}
+
+	return response.SetMetadata(loopMetadataKey(t.name), result), nil
+}
+
+// verdict evaluates the stop condition once per iteration, checkpointing the verdict
+// together with its usage
+func (t *LoopTask) verdict(ctx context.Context, llm ai.LLM, history ai.History) (*LoopVerdict, error) {
+	id := checkpointKey(ctx, "verdict", history, nil)
+
+	if verdict, ok := loadWork[LoopVerdict](ctx, id); ok {
+		return verdict, nil
+	}
+
+	verdict, err := t.condition(ctx, llm, history)
+	if err != nil {
+		return nil, err
+	}
+
+	return saveWork(ctx, id, verdict)
+}
+
+func loopMetadataKey(loop string) string {
+	return "loop:" + loop
+}
+
+// LoopResultFrom returns the result of the named loop recorded in the response
+func LoopResultFrom(response *ai.LLMResponse, loop string) (*LoopResult, bool) {
+	switch value := response.Metadata[loopMetadataKey(loop)].(type) {
+	case *LoopResult:
+		return value, true
+
+	case map[string]any:
+		// Response was decoded from JSON
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+
+		return decodeStored[LoopResult](payload)
+	}
+
+	return nil, false
+}
+
+func (t *LoopTask) definition() any {
+	return []any{taskDefinition(t.body), t.maxIterations}
+}
+
+func (t *LoopTask) Clone() Task {
+	return &LoopTask{
+		name:          t.name,
+		body:          t.body.Clone(),
+		condition:     t.condition,
+		maxIterations: t.maxIterations,
+	}
+}
+
+func (t *LoopTask) WithName(name string) Task {
+	new := t.Clone().(*LoopTask)
+	new.name = name
+	return new
+}
+
+func (t *LoopTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
+	new := t.Clone().(*LoopTask)
+	new.body = new.body.WithRequestOpts(opts...)
+	return new
+}
+
+func (t *LoopTask) Then(task Task) Task {
+	return NewChainTask(t, task, false)
+}
+
+func (t *LoopTask) Pipe(task Task) Task {
+	return NewChainTask(t, task, true)
+}
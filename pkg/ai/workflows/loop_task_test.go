@@ -0,0 +1,177 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/eval/expectations"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoopTaskTestSuite struct {
+	suite.Suite
+	*require.Assertions
+}
+
+func TestLoopTaskSuite(t *testing.T) {
+	suite.Run(t, new(LoopTaskTestSuite))
+}
+
+func (s *LoopTaskTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+// draftTask writes a numbered draft, counting drafts already in the history
+func (s *LoopTaskTestSuite) draftTask(calls *int) Task {
+	return NewLazyTask("draft", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		*calls++
+
+		drafts := 0
+		for _, message := range history {
+			if message.Role() == ai.MessageRoleAssistant {
+				drafts++
+			}
+		}
+
+		return ai.NewLLMResponse(ai.NewAssistantMessage(fmt.Sprintf("Draft %d", drafts+1))), nil
+	})
+}
+
+func untilDraft(n int) LoopCondition {
+	return UntilPredicate(func(history ai.History) bool {
+		return history.Last().(*ai.TextMessage).Content == fmt.Sprintf("Draft %d", n)
+	})
+}
+
+func (s *LoopTaskTestSuite) TestUntilPredicate() {
+	calls := 0
+	loop := NewLoopTask("revise", s.draftTask(&calls), untilDraft(2), 5)
+
+	res, err := loop.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	s.Equal(2, calls)
+
+	s.Equal(ai.NewHistory(
+		ai.NewUserMessage("Write a summary"),
+		ai.NewAssistantMessage("Draft 1"),
+		ai.NewAssistantMessage("Draft 2"),
+	), res.Messages)
+
+	result, ok := LoopResultFrom(res, "revise")
+	s.True(ok)
+	s.Equal(&LoopResult{Iterations: 2, Stopped: true}, result)
+}
+
+func (s *LoopTaskTestSuite) TestMaxIterations() {
+	calls := 0
+	loop := NewLoopTask("revise", s.draftTask(&calls), untilDraft(10), 3)
+
+	res, err := loop.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	s.Equal(3, calls)
+	s.Equal(ai.NewAssistantMessage("Draft 3"), res.Messages.Last())
+
+	result, ok := LoopResultFrom(res, "revise")
+	s.True(ok)
+	s.Equal(&LoopResult{Iterations: 3, Stopped: false}, result)
+}
+
+func (s *LoopTaskTestSuite) TestUntilJudged() {
+	var judged []string
+
+	mockLLM := &MockLLM{}
+	verdict := func(score int, reason string) *ai.LLMResponse {
+		args, _ := json.Marshal(&expectations.ScoringJudgeVerdict{Score: score, Reason: reason})
+		return ai.NewLLMResponse(ai.NewToolCallMessage(tools.NewToolCall("1", "formatter", args))).SetUsage(ai.NewLLMUsage(100, 10, 110))
+	}
+	recordDraft := func(args mock.Arguments) {
+		judged = append(judged, args.Get(1).(*ai.LLMRequest).History.Last().(*ai.TextMessage).Content)
+	}
+
+	mockLLM.On("Invoke", mock.Anything, mock.Anything).Run(recordDraft).Return(verdict(40, "Mention the affected tables."), nil).Once()
+	mockLLM.On("Invoke", mock.Anything, mock.Anything).Run(recordDraft).Return(verdict(90, "Good."), nil).Once()
+
+	calls := 0
+	loop := NewLoopTask("revise", s.draftTask(&calls), UntilJudged(ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithSystem("Score how well the draft summarises the incident."),
+	), 80), 5)
+
+	res, err := loop.Invoke(context.Background(), mockLLM, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	mockLLM.AssertExpectations(s.T())
+
+	s.Equal(2, calls)
+	s.Equal([]string{"Draft 1", "Draft 2"}, judged)
+
+	// Reason of the low score is fed back to the second iteration
+	feedback := res.Messages[2].(*ai.TextMessage)
+	s.Equal(ai.MessageRoleUser, feedback.Role())
+	s.True(strings.HasSuffix(feedback.Content, "Mention the affected tables."))
+	s.Equal(ai.NewAssistantMessage("Draft 2"), res.Messages.Last())
+
+	// Both judge calls are counted
+	s.Equal(int64(220), res.Usage.TotalTokens)
+}
+
+func (s *LoopTaskTestSuite) TestUntilJudgedWithoutDraft() {
+	body := NewPreloadTask("nothing", func(ctx context.Context) (ai.History, error) {
+		return ai.NewHistory(), nil
+	})
+	loop := NewLoopTask("revise", body, UntilJudged(ai.NewLLMRequest(ai.WithModel(ai.Claude4Sonnet)), 80), 5)
+
+	_, err := loop.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.ErrorContains(err, "loop revise iteration 0: no assistant text to judge")
+}
+
+func (s *LoopTaskTestSuite) TestResume() {
+	ctx := context.Background()
+	storage := NewMemoryStorage("revise-1")
+
+	calls := 0
+	loop := NewLoopTask("revise", s.draftTask(&calls), untilDraft(3), 5)
+
+	_, err := loop.Invoke(WithStorage(ctx, storage), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	s.Equal(3, calls)
+
+	// Every iteration checkpoints under its own key
+	s.Equal([]string{
+		"revise/0/draft", "revise/0/verdict",
+		"revise/1/draft", "revise/1/verdict",
+		"revise/2/draft", "revise/2/verdict",
+	}, checkpointPaths(s.T(), storage))
+
+	// Forget the last iteration, only that one runs again
+	deleteCheckpoints(s.T(), storage, "revise/2/")
+
+	res, err := loop.Invoke(WithStorage(ctx, storage), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	s.Equal(4, calls)
+	s.Equal(ai.NewHistory(
+		ai.NewUserMessage("Write a summary"),
+		ai.NewAssistantMessage("Draft 1"),
+		ai.NewAssistantMessage("Draft 2"),
+		ai.NewAssistantMessage("Draft 3"),
+	), res.Messages)
+}
+
+func (s *LoopTaskTestSuite) TestInvalidMaxIterations() {
+	calls := 0
+	loop := NewLoopTask("revise", s.draftTask(&calls), untilDraft(10), 0)
+
+	res, err := loop.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Write a summary")))
+	s.NoError(err)
+	s.Equal(1, calls)
+
+	result, ok := LoopResultFrom(res, "revise")
+	s.True(ok)
+	s.Equal(&LoopResult{Iterations: 1, Stopped: false}, result)
+}
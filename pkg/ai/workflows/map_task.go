@@ -0,0 +1,279 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+)
+
+// MapTask invokes a task once per item of a list, e.g. the same StructuredTask over
+// every table of a schema, and aggregates the outputs into a JSON array.
+//
+// Response of every item is checkpointed at "<map>/<key>/result@<hash>", hashing the
+// item's input, and checkpoints of the task are nested under "<map>/<key>/", e.g.
+// "tables/orders/describe@<hash>". A resumed workflow only invokes the task for the
+// items which didn't finish.
+
+// ItemSource produces the items to map over, each item is a JSON value
+type ItemSource func(ctx context.Context, history ai.History) ([]json.RawMessage, error)
+
+// ItemsFromSlice maps over a Go slice, items are marshalled to JSON
+func ItemsFromSlice[T any](items []T) ItemSource {
+	return func(ctx context.Context, history ai.History) ([]json.RawMessage, error) {
+		encoded := make([]json.RawMessage, len(items))
+		for i, item := range items {
+			payload, err := json.Marshal(item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal item %d: %w", i, err)
+			}
+
+			encoded[i] = payload
+		}
+
+		return encoded, nil
+	}
+}
+
+// ItemsFromLastMessage maps over a JSON array in the last text message of the history,
+// typically the output of a preceding StructuredTask
+func ItemsFromLastMessage() ItemSource {
+	return func(ctx context.Context, history ai.History) ([]json.RawMessage, error) {
+		text, ok := history.Last().(*ai.TextMessage)
+		if !ok {
+			return nil, fmt.Errorf("last message is not a text message")
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(text.Content), &items); err != nil {
+			return nil, fmt.Errorf("last message is not a JSON array: %w", err)
+		}
+
+		return items, nil
+	}
+}
+
+// ItemInput builds the history the task is invoked with for a single item
+type ItemInput func(item json.RawMessage, history ai.History) ai.History
+
+// ItemAsMessage passes the item alone as a user message, without the preceding history
+func ItemAsMessage(item json.RawMessage, history ai.History) ai.History {
+	return ai.NewHistory(ai.NewUserMessage(string(item)))
+}
+
+// ItemKey identifies an item in the storage, keys must be unique within the list
+type ItemKey func(item json.RawMessage, index int) string
+
+type MapTask struct {
+	name        string
+	task        Task
+	source      ItemSource
+	input       ItemInput
+	key         ItemKey
+	concurrency int
+}
+
+type MapTaskOpts = func(*MapTask)
+
+// WithItemInput sets how an item is passed to the task, defaults to ItemAsMessage
+func WithItemInput(input ItemInput) MapTaskOpts {
+	return func(t *MapTask) {
+		t.input = input
+	}
+}
+
+// WithItemKey sets how items are keyed in the storage, defaults to the index of the item.
+// Stable keys, e.g. a table name, keep checkpoints valid when the list changes.
+func WithItemKey(key ItemKey) MapTaskOpts {
+	return func(t *MapTask) {
+		t.key = key
+	}
+}
+
+// WithMapConcurrency sets how many items are processed at the same time, defaults to 4.
+// Limit of 0 or less processes all items at once.
+func WithMapConcurrency(limit int) MapTaskOpts {
+	return func(t *MapTask) {
+		t.concurrency = limit
+	}
+}
+
+func NewMapTask(name string, task Task, source ItemSource, opts ...MapTaskOpts) *MapTask {
+	t := &MapTask{
+		name:   name,
+		task:   task,
+		source: source,
+		input:  ItemAsMessage,
+		key: func(item json.RawMessage, index int) string {
+			return strconv.Itoa(index)
+		},
+		concurrency: 4,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func (t *MapTask) Name() string {
+	return t.name
+}
+
+func (t *MapTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	items, err := t.source(ctx, history)
+	if err != nil {
+		return nil, fmt.Errorf("map %s failed to list items: %w", t.name, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := t.concurrency
+	if limit <= 0 {
+		limit = max(len(items), 1)
+	}
+
+	responses := make([]*ai.LLMResponse, len(items))
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failed   error
+	)
+	sem := make(chan struct{}, limit)
+
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			key := t.key(item, i)
+
+			response, err := t.invokeItem(ctx, llm, item, key, history)
+			if err != nil {
+				// Report the first failing item rather than the cancellation of the others
+				failOnce.Do(func() {
+					failed = fmt.Errorf("map %s item %s: %w", t.name, key, err)
+					cancel()
+				})
+				return
+			}
+
+			responses[i] = response
+		}()
+	}
+
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := ai.NewLLMResponse().SetUsage(&ai.LLMUsage{})
+	outputs := make([]json.RawMessage, len(items))
+
+	for i, itemResponse := range responses {
+		if outputs[i], err = itemOutput(itemResponse); err != nil {
+			return nil, fmt.Errorf("map %s item %s: %w", t.name, t.key(items[i], i), err)
+		}
+
+		if itemResponse.Usage != nil {
+			response.AddUsage(itemResponse.Usage)
+		}
+		response.MergeMetadata(itemResponse)
+	}
+
+	payload, err := json.Marshal(outputs)
+	if err != nil {
+		return nil, fmt.Errorf("map %s failed to marshal results: %w", t.name, err)
+	}
+
+	response.Messages = history.Append(ai.NewAssistantMessage(string(payload)))
+	return response, nil
+}
+
+// invokeItem runs the task for a single item, checkpointing its response
+func (t *MapTask) invokeItem(ctx context.Context, llm ai.LLM, item json.RawMessage, key string, history ai.History) (*ai.LLMResponse, error) {
+	ctx = withTaskPath(ctx, t.name, key)
+
+	input := t.input(item, history)
+	id := checkpointKey(ctx, "result", input, t.definition)
+
+	if response, ok := loadTask(ctx, id); ok {
+		return response, nil
+	}
+
+	response, err := t.task.Invoke(ctx, llm, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return saveTask(ctx, id, response)
+}
+
+// itemOutput is the last text message of the response, embedded as JSON when it is valid JSON
+func itemOutput(response *ai.LLMResponse) (json.RawMessage, error) {
+	lastMessage := response.LastMessageAsText()
+	if lastMessage == nil {
+		return nil, fmt.Errorf("last message is not a text message")
+	}
+
+	if json.Valid([]byte(lastMessage.Content)) {
+		return json.RawMessage(lastMessage.Content), nil
+	}
+
+	return json.Marshal(lastMessage.Content)
+}
+
+func (t *MapTask) definition() any {
+	return taskDefinition(t.task)
+}
+
+func (t *MapTask) Clone() Task {
+	return &MapTask{
+		name:        t.name,
+		task:        t.task.Clone(),
+		source:      t.source,
+		input:       t.input,
+		key:         t.key,
+		concurrency: t.concurrency,
+	}
+}
+
+func (t *MapTask) WithName(name string) Task {
+	new := t.Clone().(*MapTask)
+	new.name = name
+	return new
+}
+
+func (t *MapTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
+	new := t.Clone().(*MapTask)
+	new.task = new.task.WithRequestOpts(opts...)
+	return new
+}
+
+func (t *MapTask) Then(task Task) Task {
+	return NewChainTask(t, task, false)
+}
+
+func (t *MapTask) Pipe(task Task) Task {
+	return NewChainTask(t, task, true)
+}
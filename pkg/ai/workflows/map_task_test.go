@@ -0,0 +1,164 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MapTaskTestSuite struct {
+	suite.Suite
+	*require.Assertions
+}
+
+func TestMapTaskSuite(t *testing.T) {
+	suite.Run(t, new(MapTaskTestSuite))
+}
+
+func (s *MapTaskTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+// upperTask answers with the item upper-cased, reporting a single prompt token
+func upperTask(calls *atomic.Int32) Task {
+	return NewLazyTask("upper", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		calls.Add(1)
+
+		var item string
+		if err := json.Unmarshal([]byte(history.Last().(*ai.TextMessage).Content), &item); err != nil {
+			return nil, err
+		}
+
+		return ai.NewLLMResponse(ai.NewAssistantMessage(strings.ToUpper(item))).SetUsage(&ai.LLMUsage{
+			LLMUsageTokens: ai.LLMUsageTokens{PromptTokens: 1},
+		}), nil
+	})
+}
+
+func (s *MapTaskTestSuite) TestItemsFromSlice() {
+	var calls atomic.Int32
+	task := NewMapTask("tables", upperTask(&calls), ItemsFromSlice([]string{"orders", "customers", "payments"}))
+
+	history := ai.NewHistory(ai.NewUserMessage("Describe tables"))
+	res, err := task.Invoke(context.Background(), &MockLLM{}, history)
+	s.NoError(err)
+
+	s.Len(res.Messages, 2)
+	s.Equal(history[0], res.Messages[0])
+	s.JSONEq(`["ORDERS", "CUSTOMERS", "PAYMENTS"]`, res.LastMessageAsText().Content)
+	s.Equal(int64(3), res.Usage.PromptTokens)
+}
+
+func (s *MapTaskTestSuite) TestItemsFromLastMessage() {
+	type description struct {
+		Table   string `json:"table"`
+		Summary string `json:"summary"`
+	}
+
+	mockLLM := &MockLLM{}
+	for _, table := range []string{"orders", "customers"} {
+		args, _ := json.Marshal(&description{Table: table, Summary: "Holds " + table})
+
+		mockLLM.
+			On("Invoke", mock.Anything, mock.MatchedBy(func(request *ai.LLMRequest) bool {
+				return request.History.Last().(*ai.TextMessage).Content == `"`+table+`"`
+			})).
+			Return(ai.NewLLMResponse(ai.NewToolCallMessage(tools.NewToolCall("1", "formatter", args))), nil).
+			Once()
+	}
+
+	describe := NewStructuredTask[description]("describe", ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithSystem("Describe the table."),
+	))
+
+	task := NewPreloadTask("tables", func(ctx context.Context) (ai.History, error) {
+		return ai.NewHistory(ai.NewAssistantMessage(`["orders", "customers"]`)), nil
+	}).Pipe(NewMapTask("describe-all", describe, ItemsFromLastMessage()))
+
+	res, err := task.Invoke(context.Background(), mockLLM, ai.History{})
+	s.NoError(err)
+	mockLLM.AssertExpectations(s.T())
+
+	s.JSONEq(`[
+		{"table": "orders", "summary": "Holds orders"},
+		{"table": "customers", "summary": "Holds customers"}
+	]`, res.LastMessageAsText().Content)
+}
+
+func (s *MapTaskTestSuite) TestConcurrencyLimit() {
+	var running, peak atomic.Int32
+
+	task := NewMapTask("slow", NewLazyTask("slow", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return ai.NewLLMResponse(ai.NewAssistantMessage("done")), nil
+	}), ItemsFromSlice(make([]int, 10)), WithMapConcurrency(3))
+
+	_, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.NoError(err)
+	s.Equal(int32(3), peak.Load())
+}
+
+func (s *MapTaskTestSuite) TestFailingItem() {
+	var mu sync.Mutex
+	started := 0
+
+	task := NewMapTask("tables", NewLazyTask("fail", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+
+		return nil, errors.New("warehouse unavailable")
+	}), ItemsFromSlice([]string{"orders", "customers", "payments"}), WithMapConcurrency(1), WithItemKey(func(item json.RawMessage, index int) string {
+		var table string
+		_ = json.Unmarshal(item, &table)
+		return table
+	}))
+
+	_, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.EqualError(err, "map tables item orders: warehouse unavailable")
+	s.Equal(1, started)
+}
+
+func (s *MapTaskTestSuite) TestResume() {
+	ctx := context.Background()
+	storage := NewMemoryStorage("tables-1")
+
+	var calls atomic.Int32
+	task := NewMapTask("tables", upperTask(&calls), ItemsFromSlice([]string{"orders", "customers"}))
+
+	_, err := task.Invoke(WithStorage(ctx, storage), &MockLLM{}, ai.History{})
+	s.NoError(err)
+	s.Equal(int32(2), calls.Load())
+
+	s.Equal([]string{"tables/0/result", "tables/0/upper", "tables/1/result", "tables/1/upper"}, checkpointPaths(s.T(), storage))
+
+	// Only the forgotten item runs again
+	deleteCheckpoints(s.T(), storage, "tables/1/")
+
+	res, err := task.Invoke(WithStorage(ctx, storage), &MockLLM{}, ai.History{})
+	s.NoError(err)
+	s.Equal(int32(3), calls.Load())
+	s.JSONEq(`["ORDERS", "CUSTOMERS"]`, res.LastMessageAsText().Content)
+}
@@ -0,0 +1,267 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/agent"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/prompts"
+)
+
+// ParallelTask invokes several tasks concurrently with the same history
+// and merges their outputs into a single response.
+
+// ParallelMode decides how ParallelTask handles failing branches
+type ParallelMode string
+
+const (
+	// ParallelFailFast cancels the remaining branches and fails on the first error
+	ParallelFailFast ParallelMode = "fail_fast"
+
+	// ParallelCollectErrors waits for all branches and passes failures to the merge,
+	// the task only fails when every branch failed
+	ParallelCollectErrors ParallelMode = "collect_errors"
+)
+
+// BranchResult is the outcome of a single branch of ParallelTask. Messages contain
+// only what the branch produced, without the input history. Usage is set for failed
+// branches too when known, e.g. from the response of an agent that ran out of budget.
+type BranchResult struct {
+	Name     string
+	Messages ai.History
+	Usage    *ai.LLMUsage
+	Metadata map[string]any
+	Err      error
+}
+
+// MergeFunc combines branch results, in the order of the tasks, into the messages of the response
+type MergeFunc func(results []*BranchResult) (ai.History, error)
+
+// MergeConcat concatenates messages of all successful branches
+func MergeConcat(results []*BranchResult) (ai.History, error) {
+	var merged ai.History
+	for _, result := range results {
+		if result.Err == nil {
+			merged = merged.Append(result.Messages...)
+		}
+	}
+
+	return merged, nil
+}
+
+// MergeTitledBlocks renders text of every branch as a markdown block titled by
+// the branch name, producing a single user message for the next task
+func MergeTitledBlocks(results []*BranchResult) (ai.History, error) {
+	builder := prompts.NewPromptBuilder()
+
+	for _, result := range results {
+		if result.Err != nil {
+			builder.AddBlock(fmt.Sprintf("Failed: %s", result.Err), prompts.WithTitle(result.Name), prompts.WithLevel(2))
+			continue
+		}
+
+		var texts []string
+		for _, message := range result.Messages {
+			if text, ok := message.(*ai.TextMessage); ok && text.Role() == ai.MessageRoleAssistant {
+				texts = append(texts, text.Content)
+			}
+		}
+
+		builder.AddBlock(strings.Join(texts, "\n\n"), prompts.WithTitle(result.Name), prompts.WithLevel(2))
+	}
+
+	return ai.NewHistory(builder.BuildUserMessage()), nil
+}
+
+type ParallelTask struct {
+	name  string
+	tasks []Task
+	merge MergeFunc
+	mode  ParallelMode
+}
+
+type ParallelTaskOpts = func(*ParallelTask)
+
+// WithMerge sets how branch outputs are combined, defaults to MergeConcat
+func WithMerge(merge MergeFunc) ParallelTaskOpts {
+	return func(t *ParallelTask) {
+		t.merge = merge
+	}
+}
+
+// WithParallelMode sets how failing branches are handled, defaults to ParallelFailFast
+func WithParallelMode(mode ParallelMode) ParallelTaskOpts {
+	return func(t *ParallelTask) {
+		t.mode = mode
+	}
+}
+
+func NewParallelTask(name string, tasks []Task, opts ...ParallelTaskOpts) *ParallelTask {
+	t := &ParallelTask{
+		name:  name,
+		tasks: tasks,
+		merge: MergeConcat,
+		mode:  ParallelFailFast,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func (t *ParallelTask) Name() string {
+	return t.name
+}
+
+func (t *ParallelTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*BranchResult, len(t.tasks))
+
+	var wg sync.WaitGroup
+	for i, task := range t.tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Branches are scoped by their position, branches of the same name don't share checkpoints
+			branchCtx := withTaskPath(ctx, t.name, strconv.Itoa(i))
+
+			// Clip the history so branches appending to it don't share the backing array
+			response, err := task.Invoke(branchCtx, llm, slices.Clip(history))
+
+			result := &BranchResult{Name: task.Name(), Err: err, Usage: branchUsage(response, err)}
+			if err == nil {
+				result.Messages = withoutPrefix(response.Messages, history)
+				result.Metadata = response.Metadata
+			} else if t.mode == ParallelFailFast {
+				cancel()
+			}
+
+			results[i] = result
+		}()
+	}
+
+	wg.Wait()
+
+	var errs []error
+	response := ai.NewLLMResponse().SetUsage(&ai.LLMUsage{})
+	for _, result := range results {
+		// Failed branches spent tokens as well
+		if result.Usage != nil {
+			response.AddUsage(result.Usage)
+		}
+
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("branch %s: %w", result.Name, result.Err))
+			continue
+		}
+
+		response.MergeMetadata(&ai.LLMResponse{Metadata: result.Metadata})
+	}
+
+	if len(errs) > 0 && (t.mode == ParallelFailFast || len(errs) == len(results)) {
+		return nil, errors.Join(errs...)
+	}
+
+	merged, err := t.merge(results)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Messages = history.Append(merged...)
+	return response, nil
+}
+
+// branchUsage returns usage of a branch, for a failed branch taken from the partial
+// response of an agent suspended or out of budget
+func branchUsage(response *ai.LLMResponse, err error) *ai.LLMUsage {
+	if response != nil {
+		return response.Usage
+	}
+
+	var exceeded *agent.ErrBudgetExceeded
+	if errors.As(err, &exceeded) && exceeded.Response != nil {
+		return exceeded.Response.Usage
+	}
+
+	var suspended *agent.ErrApprovalRequired
+	if errors.As(err, &suspended) && suspended.Response != nil {
+		return suspended.Response.Usage
+	}
+
+	return nil
+}
+
+// withoutPrefix strips the input history from the messages returned by a task,
+// tasks differ in whether they return it or only the new messages. Messages are
+// compared by value too, since responses loaded from storage hold decoded copies.
+func withoutPrefix(messages, prefix ai.History) ai.History {
+	if len(messages) < len(prefix) {
+		return messages
+	}
+
+	for i := range prefix {
+		if messages[i] != prefix[i] && !reflect.DeepEqual(messages[i], prefix[i]) {
+			return messages
+		}
+	}
+
+	return messages[len(prefix):]
+}
+
+func (t *ParallelTask) definition() any {
+	definitions := make([]any, len(t.tasks))
+	for i, task := range t.tasks {
+		definitions[i] = taskDefinition(task)
+	}
+
+	return definitions
+}
+
+func (t *ParallelTask) Clone() Task {
+	tasks := make([]Task, len(t.tasks))
+	for i, task := range t.tasks {
+		tasks[i] = task.Clone()
+	}
+
+	return &ParallelTask{
+		name:  t.name,
+		tasks: tasks,
+		merge: t.merge,
+		mode:  t.mode,
+	}
+}
+
+func (t *ParallelTask) WithName(name string) Task {
+	new := t.Clone().(*ParallelTask)
+	new.name = name
+	return new
+}
+
+func (t *ParallelTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
+	new := t.Clone().(*ParallelTask)
+	for i, task := range new.tasks {
+		new.tasks[i] = task.WithRequestOpts(opts...)
+	}
+
+	return new
+}
+
+func (t *ParallelTask) Then(task Task) Task {
+	return NewChainTask(t, task, false)
+}
+
+func (t *ParallelTask) Pipe(task Task) Task {
+	return NewChainTask(t, task, true)
+}
@@ -0,0 +1,122 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/agent"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ParallelTaskTestSuite struct {
+	suite.Suite
+	*require.Assertions
+}
+
+func TestParallelTaskSuite(t *testing.T) {
+	suite.Run(t, new(ParallelTaskTestSuite))
+}
+
+func (s *ParallelTaskTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+// answerTask answers after a delay, returning the input history with its answer like LazyTask does
+func answerTask(name, answer string, delay time.Duration, tokens int64) Task {
+	return NewLazyTask(name, func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		return ai.NewLLMResponse(ai.NewAssistantMessage(answer)).SetUsage(ai.NewLLMUsage(tokens, tokens, 2*tokens)), nil
+	})
+}
+
+func failingTask(name string, err error) Task {
+	return NewLazyTask(name, func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+		return nil, err
+	})
+}
+
+func (s *ParallelTaskTestSuite) TestMergeConcat() {
+	input := ai.NewHistory(ai.NewUserMessage("Investigate table orders"))
+
+	task := NewParallelTask("investigate", []Task{
+		answerTask("lineage", "Upstream is raw_orders", 30*time.Millisecond, 1),
+		answerTask("incidents", "No recent incidents", 10*time.Millisecond, 2),
+	})
+
+	res, err := task.Invoke(context.Background(), &MockLLM{}, input)
+	s.NoError(err)
+
+	// Branch order is kept regardless of which finished first
+	s.Equal(ai.NewHistory(
+		ai.NewUserMessage("Investigate table orders"),
+		ai.NewAssistantMessage("Upstream is raw_orders"),
+		ai.NewAssistantMessage("No recent incidents"),
+	), res.Messages)
+	s.Equal(int64(6), res.Usage.TotalTokens)
+	s.Equal(int64(2), res.Usage.Turns)
+}
+
+func (s *ParallelTaskTestSuite) TestMergeTitledBlocks() {
+	task := NewParallelTask("investigate", []Task{
+		answerTask("lineage", "Upstream is raw_orders", 0, 1),
+		failingTask("incidents", errors.New("timeout")),
+	}, WithMerge(MergeTitledBlocks), WithParallelMode(ParallelCollectErrors))
+
+	res, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.NoError(err)
+
+	s.Equal(ai.NewHistory(
+		ai.NewUserMessage("## lineage\n\nUpstream is raw_orders\n\n## incidents\n\nFailed: timeout"),
+	), res.Messages)
+}
+
+func (s *ParallelTaskTestSuite) TestCollectErrorsUsage() {
+	exceeded := &agent.ErrBudgetExceeded{
+		Budget:   agent.BudgetTurns,
+		Limit:    3,
+		Response: ai.NewLLMResponse().SetUsage(ai.NewLLMUsage(10, 10, 20)),
+	}
+
+	task := NewParallelTask("investigate", []Task{
+		answerTask("lineage", "Upstream is raw_orders", 0, 1),
+		failingTask("incidents", exceeded),
+	}, WithParallelMode(ParallelCollectErrors))
+
+	// Tokens spent by the failed branch are counted too
+	res, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.NoError(err)
+	s.Equal(int64(22), res.Usage.TotalTokens)
+}
+
+func (s *ParallelTaskTestSuite) TestFailFast() {
+	started := time.Now()
+
+	task := NewParallelTask("investigate", []Task{
+		answerTask("lineage", "Upstream is raw_orders", time.Minute, 1),
+		failingTask("incidents", errors.New("timeout")),
+	})
+
+	_, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.ErrorContains(err, "branch incidents: timeout")
+	s.Less(time.Since(started), 10*time.Second)
+}
+
+func (s *ParallelTaskTestSuite) TestCollectErrorsAllFailed() {
+	task := NewParallelTask("investigate", []Task{
+		failingTask("lineage", errors.New("not found")),
+		failingTask("incidents", errors.New("timeout")),
+	}, WithParallelMode(ParallelCollectErrors))
+
+	_, err := task.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.ErrorContains(err, "branch lineage: not found")
+	s.ErrorContains(err, "branch incidents: timeout")
+}
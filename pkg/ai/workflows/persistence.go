@@ -2,20 +2,42 @@ package workflows
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
 
 	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/agent"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/pkg/errors"
 )
 
+// StorageProvider hands out storage of individual workflows. Storages of different
+// workflows are isolated from each other.
 type StorageProvider interface {
 	Storage(ctx context.Context, workflowId string) Storage
 }
 
+// Storage keeps checkpoints of a single workflow. Loading a missing item returns
+// nil without an error. Implementations must be safe for concurrent use.
 type Storage interface {
 	Store(ctx context.Context, itemId string, data any) error
 	Load(ctx context.Context, itemId string) (any, error)
+
+	// Delete removes a single item, deleting a missing item is not an error
+	Delete(ctx context.Context, itemId string) error
+
+	// List returns ids of all stored items in lexical order
+	List(ctx context.Context) ([]string, error)
+
+	// Clear removes all items of the workflow
+	Clear(ctx context.Context) error
 }
 
 type MemoryStorageProvider struct {
+	mu    sync.Mutex
 	state map[string]*MemoryStorage
 }
 
@@ -23,7 +45,10 @@ func NewMemoryStorageProvider() *MemoryStorageProvider {
 	return &MemoryStorageProvider{state: make(map[string]*MemoryStorage)}
 }
 
-func (m *MemoryStorageProvider) Storage(id string) Storage {
+func (m *MemoryStorageProvider) Storage(ctx context.Context, id string) Storage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, ok := m.state[id]; !ok {
 		m.state[id] = NewMemoryStorage(id)
 	}
@@ -31,8 +56,11 @@ func (m *MemoryStorageProvider) Storage(id string) Storage {
 	return m.state[id]
 }
 
+// MemoryStorage keeps live values, Load returns the same pointer that was stored
 type MemoryStorage struct {
-	id    string
+	id string
+
+	mu    sync.RWMutex
 	state map[string]any
 }
 
@@ -41,14 +69,49 @@ func NewMemoryStorage(id string) *MemoryStorage {
 }
 
 func (m *MemoryStorage) Store(ctx context.Context, id string, data any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.state[id] = data
 	return nil
 }
 
 func (m *MemoryStorage) Load(ctx context.Context, id string) (any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return m.state[id], nil
 }
 
+func (m *MemoryStorage) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.state, id)
+	return nil
+}
+
+func (m *MemoryStorage) List(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.state))
+	for id := range m.state {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func (m *MemoryStorage) Clear(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state = make(map[string]any)
+	return nil
+}
+
 type storageKey struct{}
 
 func WithStorage(ctx context.Context, s Storage) context.Context {
@@ -64,6 +127,50 @@ func StorageFrom(ctx context.Context) (Storage, bool) {
 	return s, true
 }
 
+// encodeStored marshals an item for storages that persist bytes, raw JSON is kept as is
+func encodeStored(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case json.RawMessage:
+		return v, nil
+	case []byte:
+		return v, nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal stored item")
+	}
+
+	return payload, nil
+}
+
+// decodeStored turns a loaded item back into *T. Memory storage hands out the stored
+// pointer itself, storages persisting to disk or database return JSON bytes.
+func decodeStored[T any](value any) (*T, bool) {
+	var payload []byte
+
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case *T:
+		return v, v != nil
+	case json.RawMessage:
+		payload = v
+	case []byte:
+		payload = v
+	default:
+		return nil, false
+	}
+
+	decoded := new(T)
+	if err := json.Unmarshal(payload, decoded); err != nil {
+		slog.Warn("failed to decode stored item, ignoring it", "type", fmt.Sprintf("%T", decoded), "error", err)
+		return nil, false
+	}
+
+	return decoded, true
+}
+
 //
 // TASK PERSISTENCE
 //
@@ -78,30 +185,24 @@ func saveTask(ctx context.Context, id string, response *ai.LLMResponse) (*ai.LLM
 }
 
 func loadTask(ctx context.Context, id string) (*ai.LLMResponse, bool) {
-	storage, ok := StorageFrom(ctx)
+	response, ok := loadCheckpoint(ctx, id)
 	if !ok {
 		return nil, false
 	}
 
-	response, err := storage.Load(ctx, id)
-	if err != nil {
-		return nil, false
-	}
-
-	if response == nil {
-		return nil, false
-	}
-
-	return response.(*ai.LLMResponse), true
+	return decodeStored[ai.LLMResponse](response)
 }
 
 //
 // AGENT TASK PERSISTENCE
 //
 
+// AgentTaskState is the progress of an agent. Pending lists tool calls the agent
+// is suspended on until they are approved, see SubmitApproval.
 type AgentTaskState struct {
-	Response *ai.LLMResponse
-	Terminal bool
+	Response *ai.LLMResponse   `json:"response"`
+	Terminal bool              `json:"terminal"`
+	Pending  []*tools.ToolCall `json:"pending,omitempty"`
 }
 
 func NewAgentTaskState(response *ai.LLMResponse, terminal bool) *AgentTaskState {
@@ -122,26 +223,53 @@ func saveAgentTask(ctx context.Context, id string, response *ai.LLMResponse, ter
 }
 
 func loadAgentTask(ctx context.Context, id string) (*AgentTaskState, bool) {
-	storage, ok := StorageFrom(ctx)
+	state, ok := loadCheckpoint(ctx, id)
 	if !ok {
 		return nil, false
 	}
 
-	response, err := storage.Load(ctx, id)
-	if err != nil {
-		return nil, false
-	}
+	return decodeStored[AgentTaskState](state)
+}
 
-	if response == nil {
-		return nil, false
+// saveSuspendedAgentTask stores the conversation of an agent waiting for approval of tool calls
+func saveSuspendedAgentTask(ctx context.Context, id string, suspended *agent.ErrApprovalRequired) error {
+	storage, ok := StorageFrom(ctx)
+	if !ok {
+		return nil
 	}
 
-	state, ok := response.(*AgentTaskState)
+	state := NewAgentTaskState(suspended.Response, false)
+	state.Pending = suspended.Pending
+
+	return storage.Store(ctx, id, state)
+}
+
+//
+// APPROVALS
+//
+
+func approvalKey(toolCallId string) string {
+	return "approval:" + toolCallId
+}
+
+// SubmitApproval stores the decision over a tool call an agent is suspended on.
+// Invoking the workflow again with the same storage resumes the agent.
+func SubmitApproval(ctx context.Context, toolCallId string, decision *tools.ApprovalDecision) error {
+	storage, ok := StorageFrom(ctx)
 	if !ok {
-		return nil, false
+		return fmt.Errorf("no storage to submit approval of %s to", toolCallId)
 	}
 
-	return state, true
+	return storage.Store(ctx, approvalKey(toolCallId), decision)
+}
+
+// storageApprover reads decisions submitted with SubmitApproval
+func storageApprover(ctx context.Context, toolCall *tools.ToolCall) (*tools.ApprovalDecision, error) {
+	if decision, ok := loadWork[tools.ApprovalDecision](ctx, approvalKey(toolCall.ID)); ok {
+		return decision, nil
+	}
+
+	return nil, tools.ErrApprovalPending
 }
 
 // Agent async storage hook
@@ -168,21 +296,12 @@ func (h *AgentStorageHook) OnResponse(ctx context.Context, request *ai.LLMReques
 //
 
 func loadWork[T any](ctx context.Context, id string) (*T, bool) {
-	storage, ok := StorageFrom(ctx)
+	response, ok := loadCheckpoint(ctx, id)
 	if !ok {
 		return nil, false
 	}
 
-	response, err := storage.Load(ctx, id)
-	if err != nil {
-		return nil, false
-	}
-
-	if response == nil {
-		return nil, false
-	}
-
-	return response.(*T), true
+	return decodeStored[T](response)
 }
 
 func saveWork[T any](ctx context.Context, id string, response *T) (*T, error) {
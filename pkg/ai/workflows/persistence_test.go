@@ -0,0 +1,154 @@
+package workflows
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/agent"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+type itinerary struct {
+	Cities []string `json:"cities"`
+}
+
+// durableProviders returns constructors which reopen the same underlying storage,
+// simulating a process restart between invocations
+func durableProviders(t *testing.T) map[string]func() StorageProvider {
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "storage.db")+"?_pragma=busy_timeout(5000)")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return map[string]func() StorageProvider{
+		"file": func() StorageProvider {
+			return NewFileStorageProvider(dir)
+		},
+		"sqlite": func() StorageProvider {
+			provider, err := NewSQLiteStorageProvider(context.Background(), db)
+			require.NoError(t, err)
+			return provider
+		},
+	}
+}
+
+func TestDurableStorageResumesTasks(t *testing.T) {
+	for name, open := range durableProviders(t) {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			task := NewLazyTask("plan", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+				calls++
+				return ai.NewLLMResponse(ai.NewAssistantMessage("Visit Prague")), nil
+			})
+
+			work := NewFunctionWork("cities", func(ctx context.Context, llm ai.LLM, in *string) (*itinerary, error) {
+				calls++
+				return &itinerary{Cities: []string{*in, "Brno"}}, nil
+			})
+
+			run := func() (*ai.LLMResponse, *itinerary) {
+				ctx := WithStorage(context.Background(), open().Storage(context.Background(), "travel-1"))
+
+				response, err := task.Invoke(ctx, &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Plan a trip")))
+				require.NoError(t, err)
+
+				in := "Prague"
+				out, err := work.Invoke(ctx, &MockLLM{}, &in)
+				require.NoError(t, err)
+
+				return response, out
+			}
+
+			firstResponse, firstOut := run()
+			secondResponse, secondOut := run()
+
+			require.Equal(t, 2, calls)
+			require.Equal(t, firstResponse.Messages, secondResponse.Messages)
+			require.Equal(t, firstOut, secondOut)
+		})
+	}
+}
+
+func TestDurableStorageAgentTaskState(t *testing.T) {
+	for name, open := range durableProviders(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := WithStorage(context.Background(), open().Storage(context.Background(), "agent-1"))
+
+			response := ai.NewLLMResponse(ai.NewUserMessage("Hi"), ai.NewAssistantMessage("Hello"))
+			_, err := saveAgentTask(ctx, "agent", response, true)
+			require.NoError(t, err)
+
+			ctx = WithStorage(context.Background(), open().Storage(context.Background(), "agent-1"))
+			state, ok := loadAgentTask(ctx, "agent")
+			require.True(t, ok)
+			require.True(t, state.Terminal)
+			require.Equal(t, response.Messages, state.Response.Messages)
+
+			_, ok = loadAgentTask(ctx, "missing")
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestDurableAgentTaskApproval(t *testing.T) {
+	for name, open := range durableProviders(t) {
+		t.Run(name, func(t *testing.T) {
+			booked := 0
+			bookTool := tools.NewSimpleTool("book", "Book a flight", func(ctx context.Context, in *itinerary) (*itinerary, error) {
+				booked++
+				return in, nil
+			})
+
+			mockLLM := &MockLLM{}
+			mockLLM.
+				On("Invoke", mock.Anything, mock.Anything).
+				Return(ai.NewLLMResponse(ai.NewToolCallMessage(tools.NewToolCall("call-1", "book", json.RawMessage(`{"cities": ["Tokyo"]}`)))), nil).
+				Once()
+
+			task := NewAgentTask("travel", ai.NewLLMRequest(ai.WithTools(tools.RequireApproval(bookTool))))
+			history := ai.NewHistory(ai.NewUserMessage("Book a flight to Tokyo"))
+			storage := func() context.Context {
+				return WithStorage(context.Background(), open().Storage(context.Background(), "travel-approval"))
+			}
+
+			// Agent suspends on the booking, nothing is booked yet
+			_, err := task.Invoke(storage(), mockLLM, history)
+
+			var suspended *agent.ErrApprovalRequired
+			require.ErrorAs(t, err, &suspended)
+			require.Equal(t, "call-1", suspended.Pending[0].ID)
+			require.Equal(t, 0, booked)
+
+			// Still pending after a restart without a decision
+			_, err = task.Invoke(storage(), mockLLM, history)
+			require.ErrorAs(t, err, &suspended)
+			require.Equal(t, 0, booked)
+
+			// Approver edits the booking from another process
+			require.NoError(t, SubmitApproval(storage(), "call-1", tools.Edit(json.RawMessage(`{"cities": ["Osaka"]}`))))
+
+			mockLLM.
+				On("Invoke", mock.Anything, mock.Anything).
+				Return(ai.NewLLMResponse(ai.NewAssistantMessage("Booked Osaka")), nil).
+				Once()
+
+			response, err := task.Invoke(storage(), mockLLM, history)
+			require.NoError(t, err)
+			require.Equal(t, ai.NewAssistantMessage("Booked Osaka"), response.Messages.Last())
+			require.Equal(t, 1, booked)
+			mockLLM.AssertExpectations(t)
+
+			result := mockLLM.Calls[1].Arguments.Get(1).(*ai.LLMRequest).History.Last().(*ai.ToolResultMessage)
+			require.JSONEq(t, `{"cities": ["Osaka"]}`, string(result.Result))
+		})
+	}
+}
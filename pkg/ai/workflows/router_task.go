@@ -0,0 +1,221 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/structured"
+)
+
+// RouterTask picks one of several tasks based on the history and invokes it.
+// The decision is recorded in the response metadata, see RouteDecisionFrom.
+
+// RouteDecision is the route chosen by a RouteSelector together with the reason
+type RouteDecision struct {
+	Route  string `json:"route"`
+	Reason string `json:"reason"`
+
+	// Usage of selecting the route, added to the router response
+	Usage *ai.LLMUsage `json:"usage,omitempty"`
+}
+
+// RouteSelector chooses one of the route names for the given history
+type RouteSelector func(ctx context.Context, llm ai.LLM, history ai.History, routes []string) (*RouteDecision, error)
+
+// PredicateSelector routes using a Go function over the history
+func PredicateSelector(predicate func(history ai.History) string) RouteSelector {
+	return func(ctx context.Context, llm ai.LLM, history ai.History, routes []string) (*RouteDecision, error) {
+		return &RouteDecision{Route: predicate(history), Reason: "selected by predicate"}, nil
+	}
+}
+
+// LLMSelector asks the LLM to classify the history into one of the routes. The request
+// should explain when each route applies, the answer is constrained to an enum of route names.
+func LLMSelector(request *ai.LLMRequest, opts ...structured.LLMOpts) RouteSelector {
+	return func(ctx context.Context, llm ai.LLM, history ai.History, routes []string) (*RouteDecision, error) {
+		schema, err := routeSchema(routes)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := structured.NewLLM(schema, llm, opts...).Invoke(ctx, request.Clone(ai.WithAddedHistory(history)))
+		if err != nil {
+			return nil, err
+		}
+
+		lastMessage := response.LastMessageAsText()
+		if lastMessage == nil {
+			return nil, fmt.Errorf("last message is not a text message")
+		}
+
+		var decision RouteDecision
+		if err := json.Unmarshal([]byte(lastMessage.Content), &decision); err != nil {
+			return nil, fmt.Errorf("failed to parse route decision: %w", err)
+		}
+
+		decision.Usage = response.Usage
+		return &decision, nil
+	}
+}
+
+func routeSchema(routes []string) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"route": map[string]any{
+				"type":        "string",
+				"enum":        routes,
+				"description": "Name of the route to continue with",
+			},
+			"reason": map[string]any{
+				"type":        "string",
+				"description": "Short explanation why the route was chosen",
+			},
+		},
+		"required":             []string{"route", "reason"},
+		"additionalProperties": false,
+	})
+}
+
+type RouterTask struct {
+	name     string
+	routes   map[string]Task
+	selector RouteSelector
+}
+
+func NewRouterTask(name string, routes map[string]Task, selector RouteSelector) *RouterTask {
+	return &RouterTask{
+		name:     name,
+		routes:   routes,
+		selector: selector,
+	}
+}
+
+func (t *RouterTask) Name() string {
+	return t.name
+}
+
+func (t *RouterTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+	ctx = withTaskPath(ctx, t.name)
+
+	decision, err := t.selectRoute(ctx, llm, history)
+	if err != nil {
+		return nil, err
+	}
+
+	task, ok := t.routes[decision.Route]
+	if !ok {
+		return nil, fmt.Errorf("router %s selected unknown route %q, available routes: %s", t.name, decision.Route, strings.Join(t.routeNames(), ", "))
+	}
+
+	response, err := task.Invoke(ctx, llm, history)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add usage of the selector, response of the route may be shared, e.g. loaded from a checkpoint
+	usage := &ai.LLMUsage{}
+	for _, other := range []*ai.LLMUsage{response.Usage, decision.Usage} {
+		if other != nil {
+			usage.Add(other)
+		}
+	}
+
+	return response.Clone().
+		SetUsage(usage).
+		SetMetadata(routeMetadataKey(t.name), &RouteDecision{Route: decision.Route, Reason: decision.Reason}), nil
+}
+
+// selectRoute runs the selector once, the decision is checkpointed so a resumed workflow takes the same route.
+// Usage of the selector is checkpointed with the decision and reported again on resume.
+func (t *RouterTask) selectRoute(ctx context.Context, llm ai.LLM, history ai.History) (*RouteDecision, error) {
+	id := checkpointKey(ctx, "route", history, nil)
+
+	if decision, ok := loadWork[RouteDecision](ctx, id); ok {
+		return decision, nil
+	}
+
+	decision, err := t.selector(ctx, llm, history, t.routeNames())
+	if err != nil {
+		return nil, fmt.Errorf("router %s failed to select route: %w", t.name, err)
+	}
+
+	return saveWork(ctx, id, decision)
+}
+
+func (t *RouterTask) routeNames() []string {
+	return slices.Sorted(maps.Keys(t.routes))
+}
+
+func routeMetadataKey(router string) string {
+	return "route:" + router
+}
+
+// RouteDecisionFrom returns the decision of the named router recorded in the response
+func RouteDecisionFrom(response *ai.LLMResponse, router string) (*RouteDecision, bool) {
+	switch value := response.Metadata[routeMetadataKey(router)].(type) {
+	case *RouteDecision:
+		return value, true
+
+	case map[string]any:
+		// Response was decoded from JSON
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+
+		return decodeStored[RouteDecision](payload)
+	}
+
+	return nil, false
+}
+
+func (t *RouterTask) definition() any {
+	definitions := make(map[string]any, len(t.routes))
+	for name, task := range t.routes {
+		definitions[name] = taskDefinition(task)
+	}
+
+	return definitions
+}
+
+func (t *RouterTask) Clone() Task {
+	routes := make(map[string]Task, len(t.routes))
+	for name, task := range t.routes {
+		routes[name] = task.Clone()
+	}
+
+	return &RouterTask{
+		name:     t.name,
+		routes:   routes,
+		selector: t.selector,
+	}
+}
+
+func (t *RouterTask) WithName(name string) Task {
+	new := t.Clone().(*RouterTask)
+	new.name = name
+	return new
+}
+
+func (t *RouterTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
+	new := t.Clone().(*RouterTask)
+	for name, task := range new.routes {
+		new.routes[name] = task.WithRequestOpts(opts...)
+	}
+
+	return new
+}
+
+func (t *RouterTask) Then(task Task) Task {
+	return NewChainTask(t, task, false)
+}
+
+func (t *RouterTask) Pipe(task Task) Task {
+	return NewChainTask(t, task, true)
+}
@@ -0,0 +1,131 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/tools"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RouterTaskTestSuite struct {
+	suite.Suite
+	*require.Assertions
+}
+
+func TestRouterTaskSuite(t *testing.T) {
+	suite.Run(t, new(RouterTaskTestSuite))
+}
+
+func (s *RouterTaskTestSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *RouterTaskTestSuite) routes() map[string]Task {
+	return map[string]Task{
+		"incident": NewPreloadTask("incident", func(ctx context.Context) (ai.History, error) {
+			return ai.NewHistory(ai.NewAssistantMessage("Investigating incident")), nil
+		}),
+		"question": NewPreloadTask("question", func(ctx context.Context) (ai.History, error) {
+			return ai.NewHistory(ai.NewAssistantMessage("Answering question")), nil
+		}),
+	}
+}
+
+func (s *RouterTaskTestSuite) TestPredicateSelector() {
+	router := NewRouterTask("triage", s.routes(), PredicateSelector(func(history ai.History) string {
+		if strings.Contains(history.Last().(*ai.TextMessage).Content, "failing") {
+			return "incident"
+		}
+		return "question"
+	}))
+
+	res, err := router.Invoke(context.Background(), &MockLLM{}, ai.NewHistory(ai.NewUserMessage("Pipeline is failing")))
+	s.NoError(err)
+	s.Equal(ai.NewAssistantMessage("Investigating incident"), res.Messages.Last())
+
+	decision, ok := RouteDecisionFrom(res, "triage")
+	s.True(ok)
+	s.Equal("incident", decision.Route)
+}
+
+func (s *RouterTaskTestSuite) TestLLMSelector() {
+	var schema json.RawMessage
+
+	mockLLM := &MockLLM{}
+	mockLLM.
+		On("Invoke", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			schema = args.Get(1).(*ai.LLMRequest).Tools[0].InputSchemaRaw()
+		}).
+		Return(ai.NewLLMResponse(
+			ai.NewToolCallMessage(tools.NewToolCall("1", "formatter", json.RawMessage(`{"route": "question", "reason": "asks how a metric is computed"}`))),
+		).SetUsage(ai.NewLLMUsage(100, 10, 110)), nil).
+		Once()
+
+	router := NewRouterTask("triage", s.routes(), LLMSelector(ai.NewLLMRequest(
+		ai.WithModel(ai.Claude4Sonnet),
+		ai.WithSystem("Route incidents to 'incident' and everything else to 'question'."),
+	)))
+
+	// Router decision survives chaining and JSON round-trip for evals
+	task := router.Then(NewPreloadTask("summary", func(ctx context.Context) (ai.History, error) {
+		return ai.NewHistory(ai.NewAssistantMessage("Summary")), nil
+	}))
+
+	ctx := WithStorage(context.Background(), NewMemoryStorage("checkpoints"))
+	res, err := task.Invoke(ctx, mockLLM, ai.NewHistory(ai.NewUserMessage("How is revenue computed?")))
+	s.NoError(err)
+	mockLLM.AssertExpectations(s.T())
+
+	s.Contains(string(schema), `"enum":["incident","question"]`)
+	s.Equal(int64(110), res.Usage.TotalTokens)
+
+	// Resumed router takes the checkpointed route and still reports its usage
+	resumed, err := task.Invoke(ctx, mockLLM, ai.NewHistory(ai.NewUserMessage("How is revenue computed?")))
+	s.NoError(err)
+	mockLLM.AssertExpectations(s.T())
+	s.Equal(int64(110), resumed.Usage.TotalTokens)
+
+	payload, err := json.Marshal(res)
+	s.NoError(err)
+
+	decoded := &ai.LLMResponse{}
+	s.NoError(json.Unmarshal(payload, decoded))
+
+	decision, ok := RouteDecisionFrom(decoded, "triage")
+	s.True(ok)
+	s.Equal(&RouteDecision{Route: "question", Reason: "asks how a metric is computed"}, decision)
+}
+
+func (s *RouterTaskTestSuite) TestSharedRouteResponse() {
+	shared := ai.NewLLMResponse(ai.NewAssistantMessage("Cached answer"))
+	router := NewRouterTask("triage", map[string]Task{
+		"cached": NewLazyTask("cached", func(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
+			return shared, nil
+		}),
+	}, PredicateSelector(func(history ai.History) string {
+		return "cached"
+	}))
+
+	res, err := router.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.NoError(err)
+
+	_, ok := RouteDecisionFrom(res, "triage")
+	s.True(ok)
+	s.Nil(shared.Metadata)
+}
+
+func (s *RouterTaskTestSuite) TestUnknownRoute() {
+	router := NewRouterTask("triage", s.routes(), PredicateSelector(func(history ai.History) string {
+		return "billing"
+	}))
+
+	_, err := router.Invoke(context.Background(), &MockLLM{}, ai.History{})
+	s.ErrorContains(err, `router triage selected unknown route "billing", available routes: incident, question`)
+}
@@ -0,0 +1,116 @@
+package workflows
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const sqliteStorageSchema = `CREATE TABLE IF NOT EXISTS workflow_storage (
+	workflow_id TEXT NOT NULL,
+	item_id     TEXT NOT NULL,
+	data        BLOB NOT NULL,
+	updated_at  TIMESTAMP NOT NULL,
+	PRIMARY KEY (workflow_id, item_id)
+)`
+
+// SQLiteStorageProvider keeps items of all workflows in a single SQLite table.
+// The caller opens the database with a driver of their choice, e.g. modernc.org/sqlite,
+// with a busy timeout set when tasks write concurrently.
+type SQLiteStorageProvider struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorageProvider creates the storage table if it doesn't exist yet
+func NewSQLiteStorageProvider(ctx context.Context, db *sql.DB) (*SQLiteStorageProvider, error) {
+	if _, err := db.ExecContext(ctx, sqliteStorageSchema); err != nil {
+		return nil, errors.Wrap(err, "failed to create storage table")
+	}
+
+	return &SQLiteStorageProvider{db: db}, nil
+}
+
+func (p *SQLiteStorageProvider) Storage(ctx context.Context, workflowId string) Storage {
+	return &SQLiteStorage{db: p.db, workflowId: workflowId}
+}
+
+// SQLiteStorage stores items of one workflow as JSON. Loaded items are returned
+// as json.RawMessage and decoded by the tasks reading them.
+type SQLiteStorage struct {
+	db         *sql.DB
+	workflowId string
+}
+
+func (s *SQLiteStorage) Store(ctx context.Context, itemId string, data any) error {
+	payload, err := encodeStored(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO workflow_storage (workflow_id, item_id, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (workflow_id, item_id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		s.workflowId, itemId, payload, time.Now().UTC(),
+	)
+
+	return errors.Wrapf(err, "failed to store item %s", itemId)
+}
+
+func (s *SQLiteStorage) Load(ctx context.Context, itemId string) (any, error) {
+	var payload []byte
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT data FROM workflow_storage WHERE workflow_id = ? AND item_id = ?`,
+		s.workflowId, itemId,
+	).Scan(&payload)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load item %s", itemId)
+	}
+
+	return json.RawMessage(payload), nil
+}
+
+func (s *SQLiteStorage) Delete(ctx context.Context, itemId string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM workflow_storage WHERE workflow_id = ? AND item_id = ?`,
+		s.workflowId, itemId,
+	)
+
+	return errors.Wrapf(err, "failed to delete item %s", itemId)
+}
+
+func (s *SQLiteStorage) List(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT item_id FROM workflow_storage WHERE workflow_id = ? ORDER BY item_id`,
+		s.workflowId,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list items")
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to list items")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, errors.Wrap(rows.Err(), "failed to list items")
+}
+
+func (s *SQLiteStorage) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM workflow_storage WHERE workflow_id = ?`, s.workflowId)
+	return errors.Wrap(err, "failed to clear storage")
+}
@@ -0,0 +1,73 @@
+package workflows_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows/storagetest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestMemoryStorageProvider(t *testing.T) {
+	suite.Run(t, storagetest.NewSuite(func(t *testing.T) workflows.StorageProvider {
+		return workflows.NewMemoryStorageProvider()
+	}))
+}
+
+func TestFileStorageProvider(t *testing.T) {
+	suite.Run(t, storagetest.NewSuite(func(t *testing.T) workflows.StorageProvider {
+		return workflows.NewFileStorageProvider(t.TempDir())
+	}))
+}
+
+func TestSQLiteStorageProvider(t *testing.T) {
+	suite.Run(t, storagetest.NewSuite(func(t *testing.T) workflows.StorageProvider {
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "storage.db")+"?_pragma=busy_timeout(5000)")
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		provider, err := workflows.NewSQLiteStorageProvider(context.Background(), db)
+		require.NoError(t, err)
+
+		return provider
+	}))
+}
+
+func TestFileStorageDotIds(t *testing.T) {
+	ctx := context.Background()
+
+	parent := t.TempDir()
+	sibling := filepath.Join(parent, "sibling.txt")
+	require.NoError(t, os.WriteFile(sibling, []byte("keep"), 0o644))
+
+	provider := workflows.NewFileStorageProvider(filepath.Join(parent, "root"))
+	other := provider.Storage(ctx, "other")
+	require.NoError(t, other.Store(ctx, "item", "value"))
+
+	for _, workflowId := range []string{"..", "."} {
+		storage := provider.Storage(ctx, workflowId)
+		require.NoError(t, storage.Store(ctx, "..", "dots"))
+		require.NoError(t, storage.Store(ctx, ".", "dot"))
+
+		ids, err := storage.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{".", ".."}, ids)
+
+		require.NoError(t, storage.Clear(ctx))
+	}
+
+	require.FileExists(t, sibling)
+
+	ids, err := other.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"item"}, ids)
+
+	require.ErrorContains(t, provider.Storage(ctx, "").Clear(ctx), "workflow id must not be empty")
+}
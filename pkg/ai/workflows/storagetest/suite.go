@@ -0,0 +1,217 @@
+// Package storagetest provides a conformance suite every workflows.StorageProvider must pass.
+//
+//	func TestMyStorage(t *testing.T) {
+//		suite.Run(t, storagetest.NewSuite(func(t *testing.T) workflows.StorageProvider {
+//			return NewMyStorageProvider()
+//		}))
+//	}
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/getsynq/cloud/ai-data-sre/pkg/ai/workflows"
+	"github.com/stretchr/testify/suite"
+)
+
+// ProviderFactory creates a fresh, empty provider for every test
+type ProviderFactory func(t *testing.T) workflows.StorageProvider
+
+type Suite struct {
+	suite.Suite
+
+	factory  ProviderFactory
+	provider workflows.StorageProvider
+	ctx      context.Context
+}
+
+func NewSuite(factory ProviderFactory) *Suite {
+	return &Suite{factory: factory}
+}
+
+func (s *Suite) SetupTest() {
+	s.ctx = context.Background()
+	s.provider = s.factory(s.T())
+}
+
+type item struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+// requireStored checks the loaded value holds the expected item. Providers may return
+// the stored value itself or its JSON encoding.
+func (s *Suite) requireStored(expected any, loaded any) {
+	s.Require().NotNil(loaded)
+
+	var payload []byte
+	switch v := loaded.(type) {
+	case json.RawMessage:
+		payload = v
+	case []byte:
+		payload = v
+	default:
+		var err error
+		payload, err = json.Marshal(v)
+		s.Require().NoError(err)
+	}
+
+	want, err := json.Marshal(expected)
+	s.Require().NoError(err)
+	s.Require().JSONEq(string(want), string(payload))
+}
+
+func (s *Suite) TestStoreAndLoad() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	stored := &item{Name: "flights", Count: 2, Tags: []string{"a", "b"}}
+	s.Require().NoError(storage.Store(s.ctx, "flights", stored))
+
+	loaded, err := storage.Load(s.ctx, "flights")
+	s.Require().NoError(err)
+	s.requireStored(stored, loaded)
+}
+
+func (s *Suite) TestLoadMissing() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	loaded, err := storage.Load(s.ctx, "missing")
+	s.Require().NoError(err)
+	s.Require().Nil(loaded)
+}
+
+func (s *Suite) TestOverwrite() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	s.Require().NoError(storage.Store(s.ctx, "item", &item{Name: "first"}))
+	s.Require().NoError(storage.Store(s.ctx, "item", &item{Name: "second"}))
+
+	loaded, err := storage.Load(s.ctx, "item")
+	s.Require().NoError(err)
+	s.requireStored(&item{Name: "second"}, loaded)
+}
+
+func (s *Suite) TestSameWorkflowSharesItems() {
+	s.Require().NoError(s.provider.Storage(s.ctx, "workflow").Store(s.ctx, "item", &item{Name: "shared"}))
+
+	loaded, err := s.provider.Storage(s.ctx, "workflow").Load(s.ctx, "item")
+	s.Require().NoError(err)
+	s.requireStored(&item{Name: "shared"}, loaded)
+}
+
+func (s *Suite) TestWorkflowsAreIsolated() {
+	first := s.provider.Storage(s.ctx, "first")
+	second := s.provider.Storage(s.ctx, "second")
+
+	s.Require().NoError(first.Store(s.ctx, "item", &item{Name: "first"}))
+
+	loaded, err := second.Load(s.ctx, "item")
+	s.Require().NoError(err)
+	s.Require().Nil(loaded)
+
+	s.Require().NoError(second.Store(s.ctx, "other", &item{Name: "second"}))
+	s.Require().NoError(second.Clear(s.ctx))
+
+	ids, err := first.List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"item"}, ids)
+}
+
+func (s *Suite) TestList() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	ids, err := storage.List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Empty(ids)
+
+	for _, id := range []string{"b", "a/nested", "approval:call_1", "c d"} {
+		s.Require().NoError(storage.Store(s.ctx, id, &item{Name: id}))
+	}
+
+	ids, err = storage.List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"a/nested", "approval:call_1", "b", "c d"}, ids)
+
+	loaded, err := storage.Load(s.ctx, "a/nested")
+	s.Require().NoError(err)
+	s.requireStored(&item{Name: "a/nested"}, loaded)
+}
+
+func (s *Suite) TestDelete() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	s.Require().NoError(storage.Store(s.ctx, "kept", &item{Name: "kept"}))
+	s.Require().NoError(storage.Store(s.ctx, "deleted", &item{Name: "deleted"}))
+	s.Require().NoError(storage.Delete(s.ctx, "deleted"))
+	s.Require().NoError(storage.Delete(s.ctx, "missing"))
+
+	loaded, err := storage.Load(s.ctx, "deleted")
+	s.Require().NoError(err)
+	s.Require().Nil(loaded)
+
+	ids, err := storage.List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"kept"}, ids)
+}
+
+func (s *Suite) TestClear() {
+	storage := s.provider.Storage(s.ctx, "workflow")
+
+	s.Require().NoError(storage.Store(s.ctx, "a", &item{Name: "a"}))
+	s.Require().NoError(storage.Store(s.ctx, "b", &item{Name: "b"}))
+	s.Require().NoError(storage.Clear(s.ctx))
+
+	ids, err := storage.List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Empty(ids)
+
+	// Storage is still usable after being cleared
+	s.Require().NoError(storage.Store(s.ctx, "c", &item{Name: "c"}))
+	loaded, err := storage.Load(s.ctx, "c")
+	s.Require().NoError(err)
+	s.requireStored(&item{Name: "c"}, loaded)
+}
+
+func (s *Suite) TestConcurrentAccess() {
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			storage := s.provider.Storage(s.ctx, "workflow")
+			id := fmt.Sprintf("item-%02d", i)
+
+			if err := storage.Store(s.ctx, id, &item{Name: id, Count: i}); err != nil {
+				errs <- err
+				return
+			}
+
+			if _, err := storage.Load(s.ctx, id); err != nil {
+				errs <- err
+			}
+
+			if _, err := storage.List(s.ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.Require().NoError(err)
+	}
+
+	ids, err := s.provider.Storage(s.ctx, "workflow").List(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Len(ids, 50)
+}
@@ -51,6 +51,8 @@ func (t *StructuredTask) Clone() Task {
 	return &StructuredTask{
 		Name_:   t.Name_,
 		Request: t.Request.Clone(),
+		Opts:    t.Opts,
+		schema:  t.schema,
 	}
 }
 
@@ -74,6 +76,10 @@ func (t *StructuredTask) Invoke(ctx context.Context, llm ai.LLM, history ai.Hist
 	return response, nil
 }
 
+func (t *StructuredTask) definition() any {
+	return []any{newRequestDefinition(t.Request), t.schema}
+}
+
 func (t *StructuredTask) With(opt StructuredTaskOpts) *StructuredTask {
 	opt(t)
 	return t
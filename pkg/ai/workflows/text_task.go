@@ -29,7 +29,9 @@ func (t *TextTask) Clone() Task {
 }
 
 func (t *TextTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (*ai.LLMResponse, error) {
-	if response, ok := loadTask(ctx, t.Name_); ok {
+	key := checkpointKey(ctx, t.Name_, history, t.definition)
+
+	if response, ok := loadTask(ctx, key); ok {
 		return response, nil
 	}
 
@@ -38,7 +40,11 @@ func (t *TextTask) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (
 		return nil, err
 	}
 
-	return saveTask(ctx, t.Name_, response)
+	return saveTask(ctx, key, response)
+}
+
+func (t *TextTask) definition() any {
+	return newRequestDefinition(t.Request)
 }
 
 func (t *TextTask) WithRequestOpts(opts ...ai.LLMRequestOpts) Task {
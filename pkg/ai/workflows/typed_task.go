@@ -60,6 +60,10 @@ func (t *typed[T]) Invoke(ctx context.Context, llm ai.LLM, history ai.History) (
 	return t.Inner.Invoke(ctx, llm, history)
 }
 
+func (t *typed[T]) definition() any {
+	return taskDefinition(t.Inner)
+}
+
 func (t *typed[T]) Clone() Task {
 	return &typed[T]{
 		Inner: t.Inner.Clone(),
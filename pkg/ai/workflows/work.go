@@ -12,8 +12,9 @@ import (
 // exposing internal task that implements Task interface that can be used
 // in evals and other areas of code that demand Task interface.
 
+// Work is a typed step of a workflow, see NewFunctionWork and DAG
 type Work[I any, O any] interface {
-	Invoke(ctx context.Context, in *I) (*O, error)
+	Invoke(ctx context.Context, llm ai.LLM, in *I) (*O, error)
 }
 
 type Func[I any, O any] = func(ctx context.Context, llm ai.LLM, in *I) (*O, error)
@@ -35,7 +36,9 @@ func (t *FunctionWork[I, O]) Name() string {
 }
 
 func (t *FunctionWork[I, O]) Invoke(ctx context.Context, llm ai.LLM, in *I) (*O, error) {
-	if response, ok := loadWork[O](ctx, t.name); ok {
+	key := checkpointKey(ctx, t.name, in, nil)
+
+	if response, ok := loadWork[O](ctx, key); ok {
 		return response, nil
 	}
 
@@ -44,5 +47,5 @@ func (t *FunctionWork[I, O]) Invoke(ctx context.Context, llm ai.LLM, in *I) (*O,
 		return nil, err
 	}
 
-	return saveWork(ctx, t.name, response)
+	return saveWork(ctx, key, response)
 }